@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/reconquest/executil-go"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// actionTypePaste pastes value without prefix that is already typed
+	actionTypePaste = "paste"
+
+	// actionTypePasteValue pastes whole value
+	actionTypePasteValue = "paste-value"
+
+	// actionTypePasteTarget pastes hidden URI of hyperlink or whole value if
+	// candidate is not a hyperlink
+	actionTypePasteTarget = "paste-target"
+
+	actionTypeCopy     = "copy"
+	actionTypeOpen     = "open"
+	actionTypeEdit     = "edit"
+	actionTypeRun      = "run"
+	actionTypeSendPane = "send-pane"
+)
+
+var actionTypes = []string{
+	actionTypePaste,
+	actionTypePasteValue,
+	actionTypePasteTarget,
+	actionTypeCopy,
+	actionTypeOpen,
+	actionTypeEdit,
+	actionTypeRun,
+	actionTypeSendPane,
+}
+
+// Action describes what can be done with selected candidate, actions are
+// listed in the action menu.
+type Action struct {
+	Name string
+	Type string `required:"true"`
+
+	// Key is a key that chooses action in the menu
+	Key string
+
+	// Command is a program to run for actions of run type, it can contain
+	// placeholders like {value} or {line}, see placeholders
+	Command string
+
+	// Cwd runs command in the working directory of the pane
+	Cwd bool
+
+	// Paste pastes output of command instead of candidate
+	Paste bool
+
+	// Target is a pane to send value to for actions of send-pane type
+	Target string
+}
+
+var defaultActions = []Action{
+	{Name: "paste", Type: actionTypePaste, Key: "p"},
+	{Name: "paste whole value", Type: actionTypePasteValue, Key: "P"},
+	{Name: "paste link target", Type: actionTypePasteTarget, Key: "L"},
+	{Name: "copy to clipboard", Type: actionTypeCopy, Key: "y"},
+	{Name: "open", Type: actionTypeOpen, Key: "o"},
+	{Name: "edit", Type: actionTypeEdit, Key: "e"},
+	{Name: "send to marked pane", Type: actionTypeSendPane, Key: "s", Target: "{marked}"},
+}
+
+var reFileLocation = regexp.MustCompile(`^(.+?):(\d+)(?::\d+)?:?$`)
+
+// getMenuActions returns actions from config or default actions if config
+// has none.
+func getMenuActions(actions []Action) ([]Action, error) {
+	if len(actions) == 0 {
+		return defaultActions, nil
+	}
+
+	// bound keys are mapped to names of actions or menu
+	bound := map[Key]string{}
+	for _, name := range menuKeys {
+		key, err := parseKey(name)
+		if err != nil {
+			return nil, err
+		}
+
+		bound[key] = "menu navigation"
+	}
+
+	for _, action := range actions {
+		known := false
+		for _, actionType := range actionTypes {
+			if action.Type == actionType {
+				known = true
+			}
+		}
+
+		if !known {
+			return nil, fmt.Errorf(
+				"unknown type %q of action %q", action.Type, action.Name,
+			)
+		}
+
+		if action.Type == actionTypeRun && action.Command == "" {
+			return nil, fmt.Errorf(
+				"no command specified for action %q", action.Name,
+			)
+		}
+
+		if action.Type == actionTypeSendPane && action.Target == "" {
+			return nil, fmt.Errorf(
+				"no target pane specified for action %q", action.Name,
+			)
+		}
+
+		if action.Key != "" {
+			key, err := parseKey(action.Key)
+			if err != nil {
+				return nil, karma.Format(
+					err,
+					"invalid key of action %q", action.Name,
+				)
+			}
+
+			if name, ok := bound[key]; ok {
+				return nil, fmt.Errorf(
+					"key %q of action %q is already used by %s",
+					action.Key, action.Name, name,
+				)
+			}
+
+			bound[key] = fmt.Sprintf("action %q", action.Name)
+		}
+	}
+
+	return actions, nil
+}
+
+// getDefaultAction returns action that is performed on accept.
+func getDefaultAction(program string, cwd bool, paste bool) Action {
+	if program != "" {
+		return Action{
+			Name:    "exec",
+			Type:    actionTypeRun,
+			Command: program,
+			Cwd:     cwd,
+			Paste:   paste,
+		}
+	}
+
+	return Action{Name: "paste", Type: actionTypePaste}
+}
+
+func useCurrentCandidate(
+	tmux *Tmux,
+	pane *Pane,
+	identifier *Identifier,
+	candidates []*Candidate,
+	action Action,
+	withPrefix bool,
+	config *Config,
+) error {
+	selected := getSelectedCandidate(candidates)
+	if selected == nil {
+		return nil
+	}
+
+	text := getCandidatesText(candidates)
+
+	debug.Printf("using candidate: %s (%s)", text, action.Type)
+
+	switch action.Type {
+	case actionTypePaste:
+		if withPrefix {
+			text = getPasteText(candidates, identifier)
+		}
+
+		return tmux.Paste(text, "-t", pane.ID)
+
+	case actionTypePasteValue:
+		return tmux.Paste(text, "-t", pane.ID)
+
+	case actionTypePasteTarget:
+		return tmux.Paste(getCandidatesTarget(candidates), "-t", pane.ID)
+
+	case actionTypeCopy:
+		return copyToClipboard(tmux, config.Clipboard, text)
+
+	case actionTypeOpen:
+		if selected.Target != "" && len(getMarkedCandidates(candidates)) == 0 {
+			text = selected.Target
+		}
+
+		values, err := getTemplateValues(tmux, pane, identifier, selected, text)
+		if err != nil {
+			return err
+		}
+
+		return openURL(config.Openers, text, values)
+
+	case actionTypeEdit:
+		return editCandidate(tmux, pane, text)
+
+	case actionTypeRun:
+		output, err := runCommand(tmux, pane, identifier, selected, text, action)
+		if err != nil {
+			return err
+		}
+
+		if action.Paste {
+			return tmux.Paste(output, "-t", pane.ID)
+		}
+
+		return nil
+
+	case actionTypeSendPane:
+		return tmux.Paste(text, "-t", action.Target)
+	}
+
+	return fmt.Errorf("unexpected action type: %q", action.Type)
+}
+
+// runCommand runs command of given action with placeholders expanded and
+// returns its standard output.
+func runCommand(
+	tmux *Tmux,
+	pane *Pane,
+	identifier *Identifier,
+	selected *Candidate,
+	text string,
+	action Action,
+) (string, error) {
+	values, err := getTemplateValues(tmux, pane, identifier, selected, text)
+	if err != nil {
+		return "", err
+	}
+
+	args, err := expandCommand(action.Command, values)
+	if err != nil {
+		return "", err
+	}
+
+	debug.Printf("running command: %q", args)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), getTemplateEnv(values)...)
+
+	if action.Cwd {
+		cmd.Dir = values["pane_current_path"]
+	}
+
+	stdout, _, err := executil.Run(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(stdout), "\n"), nil
+}
+
+func getTemplateValues(
+	tmux *Tmux,
+	pane *Pane,
+	identifier *Identifier,
+	selected *Candidate,
+	text string,
+) (map[string]string, error) {
+	values := map[string]string{}
+	for _, name := range placeholders {
+		values[name] = ""
+	}
+
+	var path string
+
+	err := tmux.Eval(
+		map[string]interface{}{
+			"pane_current_path": &path,
+		},
+		"-t", pane.ID,
+	)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to get current path of pane",
+		)
+	}
+
+	values["value"] = text
+	values["pane_id"] = pane.ID
+	values["pane_current_path"] = path
+	values["x"] = fmt.Sprint(selected.X)
+	values["y"] = fmt.Sprint(selected.Y)
+	values["type"] = selected.Type()
+	values["target"] = selected.Target
+
+	if identifier != nil {
+		values["prefix"] = identifier.Value
+	}
+
+	lines := pane.GetPrintable()
+	if selected.Y < len(lines) {
+		values["line"] = lines[selected.Y]
+	}
+
+	return values, nil
+}
+
+// editCandidate opens $EDITOR in new window in the working directory of the
+// pane, value like file.go:12:4 is opened on the specified line.
+func editCandidate(tmux *Tmux, pane *Pane, value string) error {
+	var path string
+
+	err := tmux.Eval(
+		map[string]interface{}{
+			"pane_current_path": &path,
+		},
+		"-t", pane.ID,
+	)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to get current path of pane",
+		)
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+
+	command := []string{editor}
+
+	if matches := reFileLocation.FindStringSubmatch(value); matches != nil {
+		command = append(command, "+"+matches[2], shellQuote(matches[1]))
+	} else {
+		command = append(command, shellQuote(value))
+	}
+
+	return tmux.NewWindowAt(path, command...)
+}
+
+// getCandidatesText returns values of marked candidates separated by space or
+// value of selected candidate if nothing is marked.
+func getCandidatesText(candidates []*Candidate) string {
+	return joinCandidates(candidates, func(candidate *Candidate) string {
+		return candidate.Value
+	})
+}
+
+// getCandidatesTarget works like getCandidatesText, but hidden URIs are used
+// for hyperlinks.
+func getCandidatesTarget(candidates []*Candidate) string {
+	return joinCandidates(candidates, func(candidate *Candidate) string {
+		if candidate.Target != "" {
+			return candidate.Target
+		}
+
+		return candidate.Value
+	})
+}
+
+// getPasteText returns text which completes identifier typed in the pane.
+// Typed prefix completes the first value that starts with it, this value goes
+// first and other values are pasted whole, so every marked value ends up in
+// the pane. If no value starts with prefix, values are pasted after a space
+// and typed prefix is kept as is.
+func getPasteText(candidates []*Candidate, identifier *Identifier) string {
+	values := getCandidatesValues(candidates, func(candidate *Candidate) string {
+		return candidate.Value
+	})
+
+	for i, value := range values {
+		if strings.HasPrefix(value, identifier.Value) {
+			others := append(append([]string{}, values[:i]...), values[i+1:]...)
+
+			return strings.Join(
+				append([]string{value[len(identifier.Value):]}, others...),
+				" ",
+			)
+		}
+	}
+
+	return " " + strings.Join(values, " ")
+}
+
+func joinCandidates(
+	candidates []*Candidate,
+	getValue func(*Candidate) string,
+) string {
+	return strings.Join(getCandidatesValues(candidates, getValue), " ")
+}
+
+// getCandidatesValues returns values of marked candidates in reading order or
+// value of selected candidate if nothing is marked.
+func getCandidatesValues(
+	candidates []*Candidate,
+	getValue func(*Candidate) string,
+) []string {
+	marked := getMarkedCandidates(getCandidatesInReadingOrder(candidates))
+	if len(marked) == 0 {
+		if selected := getSelectedCandidate(candidates); selected != nil {
+			return []string{getValue(selected)}
+		}
+
+		return nil
+	}
+
+	values := []string{}
+	for _, candidate := range marked {
+		values = append(values, getValue(candidate))
+	}
+
+	return values
+}
+
+func shellQuote(value string) string {
+	return `'` + strings.ReplaceAll(value, `'`, `'\''`) + `'`
+}
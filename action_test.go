@@ -0,0 +1,154 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPasteText(t *testing.T) {
+	test := assert.New(t)
+
+	var (
+		identifier = &Identifier{X: 2, Y: 2, Value: "fo"}
+		foo1       = &Candidate{Identifier: &Identifier{X: 0, Y: 0, Value: "foo1"}}
+		bar        = &Candidate{Identifier: &Identifier{X: 5, Y: 0, Value: "bar"}}
+		foo2       = &Candidate{Identifier: &Identifier{X: 0, Y: 1, Value: "foo2"}}
+		candidates = []*Candidate{foo2, bar, foo1}
+	)
+
+	foo2.Selected = true
+	test.Equal("o2", getPasteText(candidates, identifier))
+
+	// prefix completes the first marked value, others are pasted whole in
+	// reading order
+	foo1.Marked, foo2.Marked = true, true
+	test.Equal("o1 foo2", getPasteText(candidates, identifier))
+
+	// value with prefix goes first
+	bar.Marked = true
+	test.Equal("o1 bar foo2", getPasteText(candidates, identifier))
+
+	// typed prefix is kept if no value starts with it
+	foo1.Marked, foo2.Marked = false, false
+	test.Equal(" bar", getPasteText(candidates, identifier))
+
+	test.Equal("bar", getPasteText(candidates, &Identifier{}))
+}
+
+func TestGetMenuActions(t *testing.T) {
+	test := assert.New(t)
+
+	actions, err := getMenuActions(nil)
+	test.NoError(err)
+	test.Equal(defaultActions, actions)
+
+	tests := []struct {
+		actions []Action
+		err     string
+	}{
+		{
+			actions: []Action{
+				{Name: "paste", Type: actionTypePaste, Key: "p"},
+				{Name: "grep", Type: actionTypeRun, Command: "rg", Key: "M-j"},
+			},
+		},
+		{
+			actions: []Action{{Name: "jump", Type: actionTypePaste, Key: "j"}},
+			err:     `key "j" of action "jump" is already used by menu navigation`,
+		},
+		{
+			actions: []Action{{Name: "up", Type: actionTypePaste, Key: "Up"}},
+			err:     `key "Up" of action "up" is already used by menu navigation`,
+		},
+		{
+			actions: []Action{{Name: "exit", Type: actionTypePaste, Key: "C-c"}},
+			err:     `key "C-c" of action "exit" is already used by menu navigation`,
+		},
+		{
+			actions: []Action{
+				{Name: "paste", Type: actionTypePaste, Key: "p"},
+				{Name: "print", Type: actionTypeRun, Command: "lp", Key: "p"},
+			},
+			err: `key "p" of action "print" is already used by action "paste"`,
+		},
+		{
+			actions: []Action{{Name: "paste", Type: actionTypePaste, Key: "C-foo"}},
+			err:     `invalid key of action "paste"`,
+		},
+		{
+			actions: []Action{{Name: "foo", Type: "foo"}},
+			err:     `unknown type "foo" of action "foo"`,
+		},
+	}
+
+	for _, testcase := range tests {
+		actions, err := getMenuActions(testcase.actions)
+		if testcase.err == "" {
+			test.NoError(err)
+			test.Equal(testcase.actions, actions)
+			continue
+		}
+
+		if test.Error(err) {
+			test.Contains(err.Error(), testcase.err)
+		}
+	}
+}
+
+func TestMenuHandle(t *testing.T) {
+	test := assert.New(t)
+
+	actions := []Action{
+		{Name: "paste", Type: actionTypePaste, Key: "p"},
+		{Name: "copy", Type: actionTypeCopy},
+		{Name: "grep", Type: actionTypeRun, Command: "rg", Key: "M-g"},
+	}
+
+	tests := []struct {
+		keys     string
+		selected int
+		action   string
+		done     bool
+	}{
+		{keys: "j", selected: 1},
+		{keys: "j j j", selected: 0},
+		{keys: "k", selected: 2},
+		{keys: "Down Down Up", selected: 1},
+		{keys: "j Enter", selected: 1, action: "copy", done: true},
+		{keys: "j p", selected: 1, action: "paste", done: true},
+		{keys: "M-g", selected: 0, action: "grep", done: true},
+		{keys: "g", selected: 0},
+		{keys: "j Escape", selected: 1, done: true},
+		{keys: "C-c", selected: 0, done: true},
+	}
+
+	for _, testcase := range tests {
+		events, err := getKeyEvents(testcase.keys)
+		test.NoError(err)
+
+		menu := &Menu{Actions: actions}
+
+		var (
+			action *Action
+			done   bool
+		)
+
+		for _, event := range events {
+			action, done = menu.handle(event)
+			if done {
+				break
+			}
+		}
+
+		test.Equal(testcase.selected, menu.Selected, testcase.keys)
+		test.Equal(testcase.done, done, testcase.keys)
+
+		name := ""
+		if action != nil {
+			name = action.Name
+		}
+
+		test.Equal(testcase.action, name, testcase.keys)
+	}
+}
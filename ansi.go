@@ -2,8 +2,411 @@ package main
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/termbox-go"
 )
 
-func moveCursor(x, y int) {
-	fmt.Printf("\x1b[%d;%dH", y+1, x+1)
+// Style describes how cell is drawn, colors are termbox colors in current
+// color mode, attributes like bold or reverse are stored in foreground.
+type Style struct {
+	Fg termbox.Attribute
+	Bg termbox.Attribute
+}
+
+var colorNames = map[string]int{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+}
+
+// styleAttrs are attributes which can be used in style.
+const styleAttrs = "bdBuIihs"
+
+// parseStyle parses style in the format used by themes: fg+attrs:bg+attrs,
+// where fg and bg are color names, 256-color palette indexes or RGB colors
+// like #1e1e2e or #fff. Attributes are: b - bold, d - dim, B - blink,
+// u - underline, I - italic, i - inverse, h - high intensity,
+// s - strikethrough.
+func parseStyle(style string) Style {
+	foreground, background := style, ""
+	if index := strings.Index(style, ":"); index >= 0 {
+		foreground, background = style[:index], style[index+1:]
+	}
+
+	fg, fgAttrs := parseStyleColor(foreground)
+	bg, bgAttrs := parseStyleColor(background)
+
+	for _, attr := range fgAttrs {
+		switch attr {
+		case 'b':
+			fg |= termbox.AttrBold
+		case 'd':
+			fg |= termbox.AttrDim
+		case 'B':
+			fg |= termbox.AttrBlink
+		case 'u':
+			fg |= termbox.AttrUnderline
+		case 'I':
+			fg |= termbox.AttrCursive
+		case 'i':
+			fg |= termbox.AttrReverse
+		case 's':
+			fg |= attrStrikethrough
+		}
+	}
+
+	if strings.ContainsRune(bgAttrs, 'b') {
+		bg |= termbox.AttrBold
+	}
+
+	return Style{Fg: fg, Bg: bg}
+}
+
+func parseStyleColor(value string) (termbox.Attribute, string) {
+	name, attrs := value, ""
+	if index := strings.Index(value, "+"); index >= 0 {
+		name, attrs = value[:index], value[index+1:]
+	}
+
+	if index, err := strconv.Atoi(name); err == nil {
+		return getColor(index), attrs
+	}
+
+	if r, g, b, ok := parseHexColor(name); ok {
+		return getRGBColor(r, g, b), attrs
+	}
+
+	index, ok := colorNames[name]
+	if !ok {
+		return termbox.ColorDefault, attrs
+	}
+
+	if strings.ContainsRune(attrs, 'h') {
+		index += 8
+	}
+
+	return getColor(index), attrs
+}
+
+// validateStyle returns error if style contains unknown colors or
+// attributes, parseStyle ignores them.
+func validateStyle(style string) error {
+	parts := strings.Split(style, ":")
+	if len(parts) > 2 {
+		return fmt.Errorf("too many colors, expected fg+attrs:bg+attrs")
+	}
+
+	for _, part := range parts {
+		name, attrs := part, ""
+		if index := strings.Index(part, "+"); index >= 0 {
+			name, attrs = part[:index], part[index+1:]
+		}
+
+		_, isName := colorNames[name]
+		_, _, _, isRGB := parseHexColor(name)
+		index, err := strconv.Atoi(name)
+
+		switch {
+		case name == "", name == "default", isName, isRGB:
+		case err == nil:
+			if index < 0 || index > 255 {
+				return fmt.Errorf("color index should be between 0 and 255: %d", index)
+			}
+		default:
+			return fmt.Errorf("unknown color: %q", name)
+		}
+
+		for _, attr := range attrs {
+			if !strings.ContainsRune(styleAttrs, attr) {
+				return fmt.Errorf("unknown attribute: %q", attr)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseHexColor parses RGB color in #rrggbb or #rgb format.
+func parseHexColor(value string) (int, int, int, bool) {
+	if !strings.HasPrefix(value, "#") {
+		return 0, 0, 0, false
+	}
+
+	digits := value[1:]
+	if len(digits) == 3 {
+		digits = strings.Repeat(digits[0:1], 2) +
+			strings.Repeat(digits[1:2], 2) +
+			strings.Repeat(digits[2:3], 2)
+	}
+
+	if len(digits) != 6 {
+		return 0, 0, 0, false
+	}
+
+	rgb, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	return int(rgb >> 16), int(rgb >> 8 & 0xff), int(rgb & 0xff), true
+}
+
+// sgrParameter is a parameter of SGR escape sequence, sub-parameters are
+// separated by colon, e.g. 4:3 or 38:2::255:0:0, empty sub-parameter is -1.
+type sgrParameter struct {
+	code int
+	sub  []int
+}
+
+// parseSGR splits parameters of SGR escape sequence (\x1b[...m), parameters
+// that are not numbers are skipped.
+func parseSGR(sequence string) []sgrParameter {
+	params := []sgrParameter{}
+
+	for _, param := range strings.Split(sequence, ";") {
+		values := []int{}
+		valid := true
+
+		for _, value := range strings.Split(param, ":") {
+			if value == "" {
+				values = append(values, -1)
+				continue
+			}
+
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 0 {
+				valid = false
+				break
+			}
+
+			values = append(values, number)
+		}
+
+		if !valid {
+			continue
+		}
+
+		// empty parameter means 0
+		if values[0] == -1 {
+			values[0] = 0
+		}
+
+		params = append(params, sgrParameter{code: values[0], sub: values[1:]})
+	}
+
+	return params
+}
+
+// applySGR applies parameters of SGR escape sequence (\x1b[...m) to given
+// style.
+func applySGR(style Style, sequence string) Style {
+	params := parseSGR(sequence)
+
+	for i := 0; i < len(params); i++ {
+		switch code := params[i].code; {
+		case code == 0:
+			style = Style{}
+
+		case code == 1:
+			style.Fg |= termbox.AttrBold
+
+		case code == 2:
+			style.Fg |= termbox.AttrDim
+
+		case code == 3:
+			style.Fg |= termbox.AttrCursive
+
+		case code == 4:
+			// 4:0 turns underline off, 4:1..4:5 are underline styles
+			if len(params[i].sub) > 0 && params[i].sub[0] == 0 {
+				style.Fg &^= termbox.AttrUnderline
+			} else {
+				style.Fg |= termbox.AttrUnderline
+			}
+
+		case code == 5 || code == 6:
+			style.Fg |= termbox.AttrBlink
+
+		case code == 7:
+			style.Fg |= termbox.AttrReverse
+
+		case code == 8:
+			style.Fg |= termbox.AttrHidden
+
+		case code == 9:
+			style.Fg |= attrStrikethrough
+
+		case code == 21:
+			// double underline
+			style.Fg |= termbox.AttrUnderline
+
+		case code == 22:
+			style.Fg &^= termbox.AttrBold | termbox.AttrDim
+
+		case code == 23:
+			style.Fg &^= termbox.AttrCursive
+
+		case code == 24:
+			style.Fg &^= termbox.AttrUnderline
+
+		case code == 25:
+			style.Fg &^= termbox.AttrBlink
+
+		case code == 27:
+			style.Fg &^= termbox.AttrReverse
+
+		case code == 28:
+			style.Fg &^= termbox.AttrHidden
+
+		case code == 29:
+			style.Fg &^= attrStrikethrough
+
+		case code >= 30 && code <= 37:
+			style.Fg = getAttrs(style.Fg) | getColor(code-30)
+
+		case code >= 90 && code <= 97:
+			style.Fg = getAttrs(style.Fg) | getColor(code-90+8)
+
+		case code == 39:
+			style.Fg = getAttrs(style.Fg)
+
+		case code >= 40 && code <= 47:
+			style.Bg = getColor(code - 40)
+
+		case code >= 100 && code <= 107:
+			style.Bg = getColor(code - 100 + 8)
+
+		case code == 49:
+			style.Bg = termbox.ColorDefault
+
+		case code == 38 || code == 48 || code == 58:
+			color, ok, skip := parseSGRColor(params[i].sub, params[i+1:])
+
+			i += skip
+
+			// 58 is underline color, it's not supported by termbox
+			if !ok || code == 58 {
+				continue
+			}
+
+			if code == 38 {
+				style.Fg = getAttrs(style.Fg) | color
+			} else {
+				style.Bg = color
+			}
+		}
+	}
+
+	return style
+}
+
+// parseSGRColor parses extended color given either as sub-parameters
+// (38:5:n, 38:2::r:g:b) or as following parameters (38;5;n, 38;2;r;g;b),
+// returns number of following parameters which have been consumed.
+func parseSGRColor(sub []int, following []sgrParameter) (termbox.Attribute, bool, int) {
+	skip := 0
+
+	if len(sub) == 0 {
+		// legacy form, sub-parameters are passed as parameters
+		for _, param := range following {
+			if len(param.sub) > 0 {
+				break
+			}
+
+			sub = append(sub, param.code)
+		}
+
+		switch {
+		case len(sub) >= 2 && sub[0] == 5:
+			skip = 2
+		case len(sub) >= 4 && sub[0] == 2:
+			skip = 4
+		default:
+			return termbox.ColorDefault, false, len(sub)
+		}
+
+		sub = sub[:skip]
+	}
+
+	switch {
+	case len(sub) == 2 && sub[0] == 5:
+		if sub[1] < 0 || sub[1] > 255 {
+			return termbox.ColorDefault, false, skip
+		}
+
+		return getColor(sub[1]), true, skip
+
+	case len(sub) >= 4 && sub[0] == 2:
+		// 38:2:r:g:b or 38:2:colorspace:r:g:b
+		rgb := sub[len(sub)-3:]
+		for _, value := range rgb {
+			if value < 0 || value > 255 {
+				return termbox.ColorDefault, false, skip
+			}
+		}
+
+		return getRGBColor(rgb[0], rgb[1], rgb[2]), true, skip
+	}
+
+	return termbox.ColorDefault, false, skip
+}
+
+// getNearestColor returns index of 256-color palette color which is the
+// closest to given RGB color, only color cube and grayscale ramp are used
+// because first 16 colors depend on terminal.
+func getNearestColor(r, g, b int) int {
+	levels := []int{0, 95, 135, 175, 215, 255}
+
+	nearestLevel := func(value int) int {
+		nearest := 0
+		for i, level := range levels {
+			if abs(level-value) < abs(levels[nearest]-value) {
+				nearest = i
+			}
+		}
+
+		return nearest
+	}
+
+	distance := func(r2, g2, b2 int) int {
+		return (r-r2)*(r-r2) + (g-g2)*(g-g2) + (b-b2)*(b-b2)
+	}
+
+	cr, cg, cb := nearestLevel(r), nearestLevel(g), nearestLevel(b)
+	cube := 16 + 36*cr + 6*cg + cb
+	cubeDistance := distance(levels[cr], levels[cg], levels[cb])
+
+	// grayscale ramp is 8, 18, ..., 238
+	gray := (r+g+b)/3 - 8
+	if gray < 0 {
+		gray = 0
+	}
+
+	gray = (gray + 5) / 10
+	if gray > 23 {
+		gray = 23
+	}
+
+	level := 8 + gray*10
+	if distance(level, level, level) < cubeDistance {
+		return 232 + gray
+	}
+
+	return cube
+}
+
+func getAttrs(value termbox.Attribute) termbox.Attribute {
+	return value & attrsMask
+}
+
+// getStyleColor returns color of style without attributes.
+func getStyleColor(value termbox.Attribute) termbox.Attribute {
+	return value &^ attrsMask
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplySGR(t *testing.T) {
+	test := assert.New(t)
+
+	testcases := []struct {
+		sequence string
+		style    Style
+	}{
+		{"", Style{}},
+		{"1;31", Style{Fg: termbox.AttrBold | getColor(1)}},
+		{"38;5;208", Style{Fg: getColor(208)}},
+		{"38:5:208;48:5:16", Style{Fg: getColor(208), Bg: getColor(16)}},
+		{"38;2;255;0;0;1", Style{Fg: getColor(196) | termbox.AttrBold}},
+		{"48:2::30:30:46", Style{Bg: getColor(235)}},
+		{"48:2:255:255:255", Style{Bg: getColor(231)}},
+		{"4:3", Style{Fg: termbox.AttrUnderline}},
+		{"4;4:0", Style{}},
+		{"1;2;22", Style{}},
+		{"7;27;3", Style{Fg: termbox.AttrCursive}},
+		{"58:5:1;32", Style{Fg: getColor(2)}},
+		{"58;2;1;2;3;32", Style{Fg: getColor(2)}},
+		{"38;5;300;32", Style{Fg: getColor(2)}},
+		{"31;x;1", Style{Fg: getColor(1) | termbox.AttrBold}},
+		{"31;;1", Style{Fg: termbox.AttrBold}},
+		{"38;5", Style{}},
+	}
+
+	for _, testcase := range testcases {
+		test.Equal(
+			testcase.style,
+			applySGR(Style{}, testcase.sequence),
+			"sequence: %q", testcase.sequence,
+		)
+	}
+}
+
+func TestParseStyleRGB(t *testing.T) {
+	test := assert.New(t)
+
+	defer func(mode string) { colorMode = mode }(colorMode)
+
+	colorMode = colorModeTruecolor
+	test.Equal(
+		Style{
+			Fg: termbox.RGBToAttribute(0xcd, 0xd6, 0xf4) | termbox.AttrCursive,
+			Bg: termbox.RGBToAttribute(0x11, 0x22, 0x33),
+		},
+		parseStyle("#cdd6f4+I:#123"),
+	)
+	test.Equal(termbox.RGBToAttribute(0, 0xcd, 0), parseStyle("green").Fg)
+
+	colorMode = colorMode256
+	test.Equal(Style{Fg: getColor(189), Bg: getColor(235)}, parseStyle("#cdd6f4:#1e1e2e"))
+	test.Equal(Style{Fg: getColor(196) | termbox.AttrBold}, applySGR(Style{}, "38;2;255;0;0;1"))
+
+	colorMode = colorMode16
+	test.Equal(Style{Fg: getColor(7), Bg: getColor(0)}, parseStyle("#cdd6f4:#1e1e2e"))
+	test.Equal(getColor(9), parseStyle("196").Fg)
+
+	test.Equal(Style{}, parseStyle("#12345:#xyz"))
+}
+
+func TestValidateStyle(t *testing.T) {
+	test := assert.New(t)
+
+	test.NoError(validateStyle("#cdd6f4+bI:235"))
+	test.NoError(validateStyle("red+hu:default"))
+	test.NoError(validateStyle("default+bs"))
+	test.EqualError(validateStyle("red+x"), `unknown attribute: 'x'`)
+	test.EqualError(validateStyle("256"), "color index should be between 0 and 255: 256")
+	test.EqualError(validateStyle("purple"), `unknown color: "purple"`)
+}
@@ -5,10 +5,10 @@ import (
 	"syscall"
 )
 
-func openBrowser(url string) {
+func openBrowser(url string) error {
 	cmd := exec.Command("xdg-open", url)
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setpgid: true,
 	}
-	cmd.Start()
+	return cmd.Start()
 }
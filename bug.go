@@ -8,57 +8,282 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"syscall"
 	"time"
+
+	"github.com/reconquest/karma-go"
 )
 
+// debugLogSize is a number of last debug messages included into report.
+const debugLogSize = 100
+
 var report = struct {
+	Tmux   string            `json:"tmux,omitempty"`
+	Term   string            `json:"term,omitempty"`
+	Locale map[string]string `json:"locale,omitempty"`
+
+	Args      map[string]interface{} `json:"args,omitempty"`
+	Theme     string                 `json:"theme,omitempty"`
+	ThemePath string                 `json:"theme_path,omitempty"`
+
 	CursorX int `json:"cursor_x,omitempty"`
 	CursorY int `json:"cursor_y,omitempty"`
 
-	Pane  *Pane    `json:"pane,omitempty"`
-	Lines []string `json:"lines,omitempty"`
-}{}
+	Pane    *Pane    `json:"pane,omitempty"`
+	Command string   `json:"pane_current_command,omitempty"`
+	Lines   []string `json:"lines,omitempty"`
+
+	Selected string   `json:"selected,omitempty"`
+	Debug    []string `json:"debug,omitempty"`
+
+	// tmux is used to get tmux version and pane command
+	tmux *Tmux
+
+	candidates []*Candidate
+
+	// redactions are applied to pane contents before writing report
+	redactions []Redaction
+
+	// context is a number of lines around cursor to keep in report,
+	// all lines are kept if it's zero
+	context int
+}{
+	redactions: defaultRedactions,
+}
+
+type ReportConfig struct {
+	// Redact is a list of regexps, matched text is removed from report
+	Redact []string
+
+	// Context is a number of lines around cursor to keep in report
+	Context int
+}
+
+// debugLog keeps last messages of debug log, so they can be included into
+// report even if --debug is not specified.
+var debugLog = &ringLog{size: debugLogSize}
+
+type ringLog struct {
+	lines []string
+	size  int
+}
+
+func (ring *ringLog) Write(data []byte) (int, error) {
+	ring.lines = append(ring.lines, strings.TrimSuffix(string(data), "\n"))
+	if len(ring.lines) > ring.size {
+		ring.lines = ring.lines[len(ring.lines)-ring.size:]
+	}
+
+	return len(data), nil
+}
 
 func writeReport(reason interface{}) {
 	if reason == nil {
 		return
 	}
 
-	encodedReport, _ := json.MarshalIndent(report, " ", "  ")
-	stacktrace := getStacktrace()
+	filename, data, err := saveReport("panic", reason)
+	if err != nil {
+		log.Printf("unable to write bug report into file: %q: %s", filename, err)
+		log.Println(string(data))
+		os.Exit(137)
+	}
 
-	filename := filepath.Join(
-		os.TempDir(),
-		"tmux-autocomplete_panic_"+time.Now().Format(time.RFC3339Nano)+".log",
+	fmt.Fprintf(os.Stderr,
+		"The program exited unexpectedly, it means that you've encountered a bug,\n"+
+			"but we have collected the bug report.\n\n"+
+			"Make sure the file doesn't have any sensitive information: %s\n"+
+			"Please help us to solve the bug by sending this report to we@reconquest.io\n",
+		filename,
 	)
 
+	os.Exit(137)
+}
+
+// writeRequestedReport writes report when it's requested using --bug-report,
+// it's used when program works incorrectly but doesn't crash.
+func writeRequestedReport() error {
+	filename, _, err := saveReport("report", "bug report requested by user")
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to write bug report into file: %s", filename,
+		)
+	}
+
+	return report.tmux.DisplayMessage(
+		"tmux-autocomplete: bug report is written to " + filename,
+	)
+}
+
+func saveReport(kind string, reason interface{}) (string, []byte, error) {
+	collectEnvironment()
+	prepareReport()
+
+	encodedReport, _ := json.MarshalIndent(report, " ", "  ")
+	stacktrace := getStacktrace()
+
 	data := []byte(
 		fmt.Sprintf(
 			"bug report: tmux-autocomplete %s %s\n\n%s\n%s\n%s\n",
 			release,
 			version,
-			reason,
+			redact(report.redactions, fmt.Sprint(reason)),
 			stacktrace,
 			encodedReport,
 		),
 	)
 
-	err := ioutil.WriteFile(filename, data, 0644)
+	filename, err := writeReportFile(kind, data)
+
+	return filename, data, err
+}
+
+// collectEnvironment fills report with things that are usually asked in bug
+// reports, errors are ignored because report should be written anyway.
+func collectEnvironment() {
+	report.Term = os.Getenv("TERM")
+
+	report.Locale = map[string]string{}
+	for _, name := range []string{"LANG", "LC_ALL", "LC_CTYPE"} {
+		if value := os.Getenv(name); value != "" {
+			report.Locale[name] = value
+		}
+	}
+
+	if report.tmux == nil {
+		return
+	}
+
+	tmuxVersion, err := report.tmux.exec("-V")
 	if err != nil {
-		log.Printf("unable to write bug report into file: %q", filename)
-		log.Println(string(data))
-		os.Exit(137)
+		debug.Printf("unable to get tmux version: %s", err)
+	} else {
+		report.Tmux = strings.TrimSpace(tmuxVersion)
 	}
 
-	fmt.Fprintf(os.Stderr,
-		"The program exited unexpectedly, it means that you've encountered a bug,\n"+
-			"but we have collected the bug report.\n\n"+
-			"Make sure the file doesn't have any sensitive information: %s\n"+
-			"Please help us to solve the bug by sending this report to we@reconquest.io\n",
-		filename,
+	if report.Pane != nil {
+		err := report.tmux.Eval(
+			map[string]interface{}{
+				"pane_current_command": &report.Command,
+			},
+			"-t", report.Pane.ID,
+		)
+		if err != nil {
+			debug.Printf("unable to get pane command: %s", err)
+		}
+	}
+}
+
+// prepareReport removes lines far from cursor and redacts secrets from the
+// pane contents.
+func prepareReport() {
+	if selected := getSelectedCandidate(report.candidates); selected != nil {
+		report.Selected = redact(report.redactions, selected.Value)
+	}
+
+	report.Debug = redactLines(report.redactions, debugLog.lines)
+
+	// command and regexps can contain secrets too
+	args := map[string]interface{}{}
+	for flag, value := range report.Args {
+		if text, ok := value.(string); ok {
+			value = redact(report.redactions, text)
+		}
+
+		args[flag] = value
+	}
+
+	report.Args = args
+
+	if report.Pane == nil {
+		return
+	}
+
+	pane := *report.Pane
+
+	if report.context > 0 && pane.Width > 0 && len(report.Lines) == len(pane.Lines) {
+		_, y := pane.GetBufferXY(report.Lines, report.CursorX, report.CursorY)
+
+		from := y - report.context
+		if from < 0 {
+			from = 0
+		}
+
+		to := y + report.context + 1
+		if to > len(report.Lines) {
+			to = len(report.Lines)
+		}
+
+		// cursor position is given in screen coordinates, so it should be
+		// shifted by number of rows removed lines took on the screen
+		_, removed := pane.GetScreenXY(report.Lines, 0, from)
+
+		report.CursorY -= removed
+		report.Lines = report.Lines[from:to]
+		pane.Lines = pane.Lines[from:to]
+	}
+
+	pane.Lines = redactLines(report.redactions, pane.Lines)
+	report.Lines = redactLines(report.redactions, report.Lines)
+	report.Pane = &pane
+}
+
+// writeReportFile writes report into directory that is accessible only by
+// current user, because report can contain sensitive data.
+func writeReportFile(kind string, data []byte) (string, error) {
+	dir, err := getUserDir()
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(
+		dir,
+		"tmux-autocomplete_"+kind+"_"+time.Now().Format(time.RFC3339Nano)+".log",
 	)
 
-	os.Exit(137)
+	err = ioutil.WriteFile(filename, data, 0600)
+	if err != nil {
+		return filename, err
+	}
+
+	return filename, nil
+}
+
+// getUserDir returns temporary directory of current user, directory is
+// created if it doesn't exist.
+func getUserDir() (string, error) {
+	dir := filepath.Join(
+		os.TempDir(),
+		fmt.Sprintf("tmux-autocomplete-%d", os.Getuid()),
+	)
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return dir, err
+	}
+
+	// directory is in shared temporary directory, so it can be created by
+	// another user beforehand or replaced by symlink
+	stat, err := os.Lstat(dir)
+	if err != nil {
+		return dir, err
+	}
+
+	if !stat.IsDir() {
+		return dir, fmt.Errorf("not a directory: %s", dir)
+	}
+
+	if sys, ok := stat.Sys().(*syscall.Stat_t); ok && int(sys.Uid) != os.Getuid() {
+		return dir, fmt.Errorf("directory is owned by another user: %s", dir)
+	}
+
+	if stat.Mode().Perm()&0077 != 0 {
+		return dir, fmt.Errorf("directory is accessible by other users: %s", dir)
+	}
+
+	return dir, nil
 }
 
 func getStacktrace() []byte {
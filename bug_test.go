@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserDir(t *testing.T) {
+	test := assert.New(t)
+
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	dir, err := getUserDir()
+	test.NoError(err)
+	test.Equal(filepath.Join(tmp, fmt.Sprintf("tmux-autocomplete-%d", os.Getuid())), dir)
+
+	test.NoError(os.Remove(dir))
+	test.NoError(os.Symlink(t.TempDir(), dir))
+
+	_, err = getUserDir()
+	test.EqualError(err, "not a directory: "+dir)
+
+	test.NoError(os.Remove(dir))
+	test.NoError(os.Mkdir(dir, 0755))
+
+	_, err = getUserDir()
+	test.EqualError(err, "directory is accessible by other users: "+dir)
+
+	if os.Getuid() == 0 {
+		test.NoError(os.Chmod(dir, 0700))
+		test.NoError(os.Chown(dir, 1, 1))
+
+		_, err = getUserDir()
+		test.EqualError(err, "directory is owned by another user: "+dir)
+	}
+}
+
+func TestSaveReport(t *testing.T) {
+	test := assert.New(t)
+
+	defer func(saved []string) { debugLog.lines = saved }(debugLog.lines)
+
+	saved := report
+	defer func() { report = saved }()
+
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("TERM", "tmux-256color")
+	t.Setenv("LANG", "en_US.UTF-8")
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_CTYPE", "C.UTF-8")
+
+	report.tmux = &Tmux{
+		backend: func(args []string, stdin io.Reader) (string, error) {
+			switch strings.Join(args, " ") {
+			case "-V":
+				return "tmux 3.3a\n", nil
+			case "display-message -p -t %1 #{pane_current_command}":
+				return "mysql\n", nil
+			}
+
+			return "", fmt.Errorf("unexpected args: %q", args)
+		},
+	}
+	report.Args = map[string]interface{}{
+		"--exec":  "mysql --password=hunter22",
+		"--debug": nil,
+		"-W":      true,
+	}
+	report.Pane = &Pane{ID: "%1", Lines: []string{"token=abcdef"}}
+	debugLog.lines = []string{"starting", "using candidate: password=hunter22"}
+
+	filename, data, err := saveReport("report", "requested")
+	test.NoError(err)
+	test.FileExists(filename)
+
+	test.Equal("tmux 3.3a", report.Tmux)
+	test.Equal("tmux-256color", report.Term)
+	test.Equal(map[string]string{"LANG": "en_US.UTF-8", "LC_CTYPE": "C.UTF-8"}, report.Locale)
+	test.Equal("mysql", report.Command)
+	test.Equal(
+		map[string]interface{}{
+			"--exec":  "mysql --password=" + redacted,
+			"--debug": nil,
+			"-W":      true,
+		},
+		report.Args,
+	)
+	test.Equal([]string{"token=" + redacted}, report.Pane.Lines)
+	test.Equal([]string{"starting", "using candidate: password=" + redacted}, report.Debug)
+	test.NotContains(string(data), "hunter22")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"os/exec"
+
+	"github.com/reconquest/executil-go"
+	"github.com/reconquest/karma-go"
+)
+
+type Clipboard struct {
+	// Buffer is a name of tmux buffer to copy value into, automatically
+	// named buffer is used if not specified
+	Buffer string
+
+	// OSC52 enables copying to the system clipboard of the terminal using
+	// OSC 52 escape sequence, it works over ssh too
+	OSC52 bool
+
+	// Command is a shell command that receives value on stdin,
+	// for example: xclip -selection clipboard
+	Command string
+}
+
+func copyToClipboard(tmux *Tmux, clipboard Clipboard, value string) error {
+	args := []string{}
+	if clipboard.Buffer != "" {
+		args = append(args, "-b", clipboard.Buffer)
+	}
+
+	err := tmux.SetBuffer(value, args...)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to set tmux buffer",
+		)
+	}
+
+	if clipboard.OSC52 {
+		err := copyUsingOSC52(tmux, value)
+		if err != nil {
+			return karma.Format(
+				err,
+				"unable to copy using OSC 52",
+			)
+		}
+	}
+
+	if clipboard.Command != "" {
+		cmd := exec.Command("sh", "-c", clipboard.Command)
+		cmd.Stdin = bytes.NewBufferString(value)
+
+		_, _, err := executil.Run(cmd)
+		if err != nil {
+			return karma.
+				Describe("command", clipboard.Command).
+				Format(
+					err,
+					"unable to run clipboard command",
+				)
+		}
+	}
+
+	return nil
+}
+
+// copyUsingOSC52 writes escape sequence directly to the tty of tmux client,
+// so the sequence is handled by terminal emulator instead of tmux.
+func copyUsingOSC52(tmux *Tmux, value string) error {
+	var tty string
+
+	err := tmux.Eval(
+		map[string]interface{}{
+			"client_tty": &tty,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(tty, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = file.WriteString(
+		"\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(value)) + "\a",
+	)
+
+	return err
+}
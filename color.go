@@ -0,0 +1,187 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	colorModeAuto      = "auto"
+	colorMode16        = "16"
+	colorMode256       = "256"
+	colorModeTruecolor = "truecolor"
+)
+
+// colorMode is a color capability of terminal, colors of theme and pane are
+// downgraded to it.
+var colorMode = colorMode256
+
+// attrsMask covers attributes of termbox color, palette index is stored in
+// lower bits and RGB value is stored in higher bits.
+const attrsMask = termbox.AttrBold | termbox.AttrBlink | termbox.AttrHidden |
+	termbox.AttrDim | termbox.AttrUnderline | termbox.AttrCursive |
+	termbox.AttrReverse | attrStrikethrough
+
+// attrStrikethrough isn't supported by termbox, so it's stored in the bit
+// which is used neither by palette nor by RGB colors.
+const attrStrikethrough termbox.Attribute = 1 << 62
+
+// basicColors are RGB values of the first 16 colors of xterm palette, real
+// values depend on terminal.
+var basicColors = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// getColor converts 256-color palette index into termbox color.
+func getColor(index int) termbox.Attribute {
+	switch colorMode {
+	case colorModeTruecolor:
+		r, g, b := getPaletteRGB(index)
+		return termbox.RGBToAttribute(uint8(r), uint8(g), uint8(b))
+
+	case colorMode16:
+		if index >= 16 {
+			index = getNearestBasicColor(getPaletteRGB(index))
+		}
+	}
+
+	return termbox.Attribute(index + 1)
+}
+
+// getRGBColor converts RGB color into termbox color, color is replaced by
+// the nearest palette color if terminal doesn't support truecolor.
+func getRGBColor(r, g, b int) termbox.Attribute {
+	switch colorMode {
+	case colorModeTruecolor:
+		return termbox.RGBToAttribute(uint8(r), uint8(g), uint8(b))
+
+	case colorMode16:
+		return getColor(getNearestBasicColor(r, g, b))
+	}
+
+	return getColor(getNearestColor(r, g, b))
+}
+
+// getPaletteRGB returns RGB value of 256-color palette color.
+func getPaletteRGB(index int) (int, int, int) {
+	switch {
+	case index < 16:
+		color := basicColors[index]
+		return color[0], color[1], color[2]
+
+	case index < 232:
+		index -= 16
+
+		level := func(value int) int {
+			if value == 0 {
+				return 0
+			}
+
+			return 55 + value*40
+		}
+
+		return level(index / 36), level(index / 6 % 6), level(index % 6)
+
+	default:
+		gray := 8 + (index-232)*10
+		return gray, gray, gray
+	}
+}
+
+// getNearestBasicColor returns index of the closest color among the first 16
+// colors of palette.
+func getNearestBasicColor(r, g, b int) int {
+	nearest, nearestDistance := 0, -1
+
+	for index, color := range basicColors {
+		distance := (r-color[0])*(r-color[0]) +
+			(g-color[1])*(g-color[1]) +
+			(b-color[2])*(b-color[2])
+
+		if nearestDistance < 0 || distance < nearestDistance {
+			nearest, nearestDistance = index, distance
+		}
+	}
+
+	return nearest
+}
+
+// detectColorMode guesses color capability of terminal attached to tmux
+// client. Inside of tmux $TERM describes tmux itself, so tmux is asked which
+// features outer terminal has.
+func detectColorMode(tmux *Tmux) string {
+	switch colorterm := os.Getenv("COLORTERM"); colorterm {
+	case "truecolor", "24bit":
+		debug.Printf("colors: $COLORTERM is %q, using truecolor", colorterm)
+		return colorModeTruecolor
+	}
+
+	var features, termname string
+
+	// client_termfeatures is available since tmux 3.2
+	err := tmux.Eval(
+		map[string]interface{}{
+			"client_termfeatures": &features,
+			"client_termname":     &termname,
+		},
+	)
+	if err != nil {
+		debug.Printf("colors: unable to get client features: %s", err)
+	}
+
+	if features != "" {
+		debug.Printf("colors: client %q has features: %s", termname, features)
+
+		list := strings.Split(features, ",")
+		switch {
+		case hasString(list, "RGB"):
+			return colorModeTruecolor
+		case hasString(list, "256"):
+			return colorMode256
+		default:
+			return colorMode16
+		}
+	}
+
+	for _, option := range []string{"terminal-overrides", "terminal-features"} {
+		value, err := tmux.ShowOption(option, "-s")
+		if err != nil {
+			debug.Printf("colors: unable to get %s: %s", option, err)
+			continue
+		}
+
+		for _, entry := range strings.Fields(value) {
+			fields := strings.Split(entry, ":")
+
+			matched, _ := filepath.Match(fields[0], termname)
+			if !matched && fields[0] != "*" {
+				continue
+			}
+
+			if hasString(fields[1:], "Tc") || hasString(fields[1:], "RGB") {
+				debug.Printf("colors: %s has %q, using truecolor", option, entry)
+				return colorModeTruecolor
+			}
+		}
+	}
+
+	debug.Printf("colors: truecolor is not detected, using 256 colors")
+
+	return colorMode256
+}
+
+func hasString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/reconquest/karma-go"
+)
+
+// completionCacheTTL is short enough to not show stale candidates and long
+// enough to not capture panes on every Tab press.
+const completionCacheTTL = 2 * time.Second
+
+// complete prints candidates that start with given word, candidates are
+// searched in the pane where shell is running or in all panes of its window.
+// It's supposed to be called by shell completion functions.
+func complete(args map[string]interface{}, tmux *Tmux) error {
+	target := os.Getenv("TMUX_PANE")
+	if target == "" {
+		return fmt.Errorf("TMUX_PANE is not set, not running inside tmux")
+	}
+
+	word, _ := args["<word>"].(string)
+
+	lines, err := getCompletionLines(tmux, target, args["--window"].(bool))
+	if err != nil {
+		return err
+	}
+
+	words, err := getCompletionWords(args["--regexp-candidate"].(string), lines, word)
+	if err != nil {
+		return err
+	}
+
+	for _, word := range words {
+		fmt.Println(word)
+	}
+
+	return nil
+}
+
+// getCompletionWords returns unique candidates that start with given word,
+// candidates closest to the prompt go first.
+func getCompletionWords(regexpCandidate string, lines []string, word string) ([]string, error) {
+	candidates, err := getCompletionCandidates(
+		regexpCandidate,
+		lines,
+		// there is no identifier on the screen, shell passes it
+		&Identifier{X: -1, Y: -1, Value: word},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	words := []string{}
+	seen := map[string]bool{}
+	for i := len(candidates) - 1; i >= 0; i-- {
+		value := candidates[i].Value
+		if seen[value] {
+			continue
+		}
+
+		seen[value] = true
+
+		words = append(words, value)
+	}
+
+	return words, nil
+}
+
+// getCompletionLines returns printable lines of the target pane or all panes
+// of its window, captured lines are cached for a short time.
+func getCompletionLines(tmux *Tmux, target string, window bool) ([]string, error) {
+	// cache is skipped if user directory is unavailable
+	cache, err := getCompletionCachePath(target, window)
+	if err != nil {
+		debug.Printf("unable to use completion cache: %s", err)
+	}
+
+	if cache != "" {
+		stat, err := os.Stat(cache)
+		if err == nil && time.Since(stat.ModTime()) < completionCacheTTL {
+			contents, err := ioutil.ReadFile(cache)
+			if err == nil {
+				debug.Printf("using completion cache: %s", cache)
+
+				return strings.Split(string(contents), "\n"), nil
+			}
+		}
+	}
+
+	panes := []string{target}
+	if window {
+		panes, err = tmux.ListPanes("-t", target)
+		if err != nil {
+			return nil, karma.Format(
+				err,
+				"unable to list panes of window",
+			)
+		}
+	}
+
+	lines := []string{}
+	for _, id := range panes {
+		contents, err := tmux.CapturePane("-J", "-t", id)
+		if err != nil {
+			return nil, karma.Format(
+				err,
+				"unable to capture pane: %s", id,
+			)
+		}
+
+		pane := &Pane{
+			ID:    id,
+			Lines: strings.Split(strings.TrimRight(contents, "\n"), "\n"),
+		}
+
+		lines = append(lines, pane.GetPrintable()...)
+	}
+
+	if cache != "" {
+		err := ioutil.WriteFile(cache, []byte(strings.Join(lines, "\n")), 0600)
+		if err != nil {
+			debug.Printf("unable to write completion cache: %s", err)
+		}
+	}
+
+	return lines, nil
+}
+
+func getCompletionCachePath(target string, window bool) (string, error) {
+	name := "complete-" + strings.TrimPrefix(target, "%")
+	if window {
+		name += "-window"
+	}
+
+	dir, err := getUserDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name), nil
+}
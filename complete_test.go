@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCompletionWords(t *testing.T) {
+	test := assert.New(t)
+
+	words, err := getCompletionWords(
+		defaultRegexpCandidate,
+		[]string{
+			"foo1 (foo2) bar",
+			"foo3 foo1",
+			"$ echo",
+		},
+		"foo",
+	)
+	test.NoError(err)
+	test.Equal([]string{"foo1", "foo3", "foo2", "foo2)"}, words)
+}
+
+func TestGetCompletionLinesCache(t *testing.T) {
+	test := assert.New(t)
+
+	t.Setenv("TMPDIR", t.TempDir())
+
+	captured := map[string]string{"%1": "foo1\n", "%2": "foo2\n"}
+	captures := 0
+
+	tmux := &Tmux{
+		backend: func(args []string, stdin io.Reader) (string, error) {
+			switch args[0] {
+			case "capture-pane":
+				captures++
+				return captured[args[len(args)-1]], nil
+			case "list-panes":
+				return "%1\n%2\n", nil
+			}
+
+			return "", nil
+		},
+	}
+
+	lines, err := getCompletionLines(tmux, "%1", false)
+	test.NoError(err)
+	test.Equal([]string{"foo1"}, lines)
+	test.Equal(1, captures)
+
+	// pane is not captured again while cache is fresh
+	captured["%1"] = "bar1\n"
+
+	lines, err = getCompletionLines(tmux, "%1", false)
+	test.NoError(err)
+	test.Equal([]string{"foo1"}, lines)
+	test.Equal(1, captures)
+
+	// window has own cache
+	lines, err = getCompletionLines(tmux, "%1", true)
+	test.NoError(err)
+	test.Equal([]string{"bar1", "foo2"}, lines)
+	test.Equal(3, captures)
+
+	// stale cache is ignored
+	cache, err := getCompletionCachePath("%1", false)
+	test.NoError(err)
+
+	stale := time.Now().Add(-completionCacheTTL)
+	err = os.Chtimes(cache, stale, stale)
+	test.NoError(err)
+
+	lines, err = getCompletionLines(tmux, "%1", false)
+	test.NoError(err)
+	test.Equal([]string{"bar1"}, lines)
+	test.Equal(4, captures)
+
+	// cache is not used at all if user directory is unavailable, file with
+	// the same name in current directory is not read
+	dir := filepath.Dir(cache)
+	test.NoError(os.RemoveAll(dir))
+	test.NoError(os.WriteFile(dir, nil, 0600))
+
+	wd, err := os.Getwd()
+	test.NoError(err)
+
+	defer os.Chdir(wd)
+
+	test.NoError(os.Chdir(t.TempDir()))
+	test.NoError(os.WriteFile("complete-1", []byte("stale"), 0600))
+
+	lines, err = getCompletionLines(tmux, "%1", false)
+	test.NoError(err)
+	test.Equal([]string{"bar1"}, lines)
+	test.Equal(5, captures)
+
+	contents, err := os.ReadFile("complete-1")
+	test.NoError(err)
+	test.Equal("stale", string(contents))
+}
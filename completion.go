@@ -2,16 +2,45 @@ package main
 
 import (
 	"regexp"
+	"sort"
 	"strings"
 )
 
 var trimRight = `)]"':`
 
+// maxPaneWidth is the widest window tmux allows, cursor can't be placed
+// further from the end of line.
+const maxPaneWidth = 10000
+
+const (
+	candidateTypeURL    = "url"
+	candidateTypePath   = "path"
+	candidateTypeHash   = "hash"
+	candidateTypeNumber = "number"
+	candidateTypeWord   = "word"
+)
+
+var (
+	reCandidateURL    = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+	reCandidatePath   = regexp.MustCompile(`^(~|\.{1,2})?/|^[^/]+/[^/]`)
+	reCandidateHash   = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+	reCandidateNumber = regexp.MustCompile(`^[-+]?[0-9]+([.,][0-9]+)*$`)
+	// IP addresses and versions like 1.2.3 look like numbers, but they are
+	// words
+	reCandidateDotted = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+){2,}$`)
+)
+
 type Candidate struct {
 	*Identifier
 
 	Selected bool
+	Marked   bool
+	Matched  bool
 	Parent   string
+
+	// Target is a value that is opened instead of value, for example,
+	// hidden URI of OSC 8 hyperlink
+	Target string
 }
 
 type Identifier struct {
@@ -25,13 +54,48 @@ func (identifier *Identifier) Length() int {
 	return len([]rune(identifier.Value))
 }
 
+// Type returns kind of candidate value: url, path, hash, number or word.
+func (candidate *Candidate) Type() string {
+	switch value := candidate.Value; {
+	case reCandidateURL.MatchString(value),
+		reCandidateURL.MatchString(candidate.Target):
+		return candidateTypeURL
+	case reCandidateDotted.MatchString(value):
+		return candidateTypeWord
+	case reCandidateNumber.MatchString(value):
+		return candidateTypeNumber
+	case reCandidateHash.MatchString(value) &&
+		strings.ContainsAny(value, "0123456789"):
+		return candidateTypeHash
+	case reCandidatePath.MatchString(value):
+		return candidateTypePath
+	default:
+		return candidateTypeWord
+	}
+}
+
 func getIdentifierToComplete(
 	regexpCursor string,
 	lines []string,
 	x int,
 	y int,
 ) (*Identifier, error) {
-	textBeforeCursor := string([]rune(lines[y])[:x])
+	if y < 0 || y >= len(lines) || x < 0 {
+		return nil, nil
+	}
+
+	symbols := []rune(lines[y])
+	if x-len(symbols) > maxPaneWidth {
+		return nil, nil
+	}
+
+	if x > len(symbols) {
+		// capture-pane trims trailing spaces, but cursor can be placed after
+		// them
+		symbols = append(symbols, []rune(strings.Repeat(" ", x-len(symbols)))...)
+	}
+
+	textBeforeCursor := string(symbols[:x])
 
 	matcher, err := regexp.Compile(
 		`^.*?(` + regexpCursor + `)$`,
@@ -47,7 +111,7 @@ func getIdentifierToComplete(
 	}
 
 	return &Identifier{
-		X: x - len(matches[1]),
+		X: x - len([]rune(matches[1])),
 		Y: y,
 
 		Value: matches[1],
@@ -265,16 +329,24 @@ func selectNextCandidate(
 				continue
 			}
 
+			// length matters only for candidates at the same position,
+			// otherwise farther candidate can win over closer one
+			if distanceX > 0 {
+				continue
+			}
+
+			// candidates at the same position as selected are already
+			// filtered by length in isNextCandidate
 			if dirX == 1 {
 				// looking for size greater than selected but the difference
 				// should be as small as possible
-				if candidate.Length() > selected.Length() && candidate.Length() < closest.Length() {
+				if candidate.Length() < closest.Length() {
 					closest = candidate
 				}
 			} else {
 				// looking for size less than selected but the difference
 				// should be as small as possible (near to selected as possible)
-				if candidate.Length() < selected.Length() && candidate.Length() > closest.Length() {
+				if candidate.Length() > closest.Length() {
 					closest = candidate
 				}
 			}
@@ -336,6 +408,15 @@ func isNextCandidate(selected *Candidate, dirX, dirY int, candidate *Candidate)
 	return false
 }
 
+// getCandidateScore returns proximity of candidate to given position, score is
+// between 0 and 1, candidates on the same line have greater score than
+// candidates on other lines.
+func getCandidateScore(candidate *Candidate, x int, y int) float64 {
+	distance := float64(abs(candidate.Y-y)) + float64(abs(candidate.X-x))/1000
+
+	return 1 / (1 + distance)
+}
+
 func abs(x int) int {
 	if x < 0 {
 		x = -x
@@ -355,6 +436,88 @@ func sign(value int) int {
 	}
 }
 
+func getMarkedCandidates(candidates []*Candidate) []*Candidate {
+	marked := []*Candidate{}
+	for _, candidate := range candidates {
+		if candidate.Marked {
+			marked = append(marked, candidate)
+		}
+	}
+
+	return marked
+}
+
+// getCandidatesInReadingOrder returns candidates sorted from top to bottom and
+// from left to right, candidates on the same position are sorted by length, so
+// nested candidates go before their parents.
+func getCandidatesInReadingOrder(candidates []*Candidate) []*Candidate {
+	ordered := make([]*Candidate, len(candidates))
+	copy(ordered, candidates)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+
+		if a.X != b.X {
+			return a.X < b.X
+		}
+
+		return a.Length() < b.Length()
+	})
+
+	return ordered
+}
+
+// selectCandidateInOrder moves selection by given step in reading order,
+// selection wraps around at the first and the last candidates.
+func selectCandidateInOrder(candidates []*Candidate, step int) {
+	selected := getSelectedCandidate(candidates)
+	if selected == nil {
+		return
+	}
+
+	ordered := getCandidatesInReadingOrder(candidates)
+
+	for index, candidate := range ordered {
+		if candidate != selected {
+			continue
+		}
+
+		next := (index + step) % len(ordered)
+		if next < 0 {
+			next += len(ordered)
+		}
+
+		selected.Selected = false
+		ordered[next].Selected = true
+
+		return
+	}
+}
+
+func selectFirstCandidate(candidates []*Candidate) {
+	selectCandidateAt(candidates, 0)
+}
+
+func selectLastCandidate(candidates []*Candidate) {
+	selectCandidateAt(candidates, len(candidates)-1)
+}
+
+func selectCandidateAt(candidates []*Candidate, index int) {
+	if len(candidates) == 0 {
+		return
+	}
+
+	if selected := getSelectedCandidate(candidates); selected != nil {
+		selected.Selected = false
+	}
+
+	getCandidatesInReadingOrder(candidates)[index].Selected = true
+}
+
 func getUniqueCandidates(candidates []*Candidate) []*Candidate {
 	uniques := []*Candidate{}
 
@@ -363,7 +526,9 @@ mainLoop:
 		candidate := candidates[i]
 
 		for _, unique := range uniques {
-			if unique.Value == candidate.Value && unique.Parent == candidate.Parent {
+			if unique.Value == candidate.Value &&
+				unique.Parent == candidate.Parent &&
+				unique.Target == candidate.Target {
 				continue mainLoop
 			}
 		}
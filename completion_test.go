@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,11 +18,91 @@ type testcase struct {
 	cursorX  int
 	cursorY  int
 	expected []string
+
+	// steps are keys pressed one by one with value which should be selected
+	// after each key, key is - for default selection
+	steps []step
+}
+
+type step struct {
+	key      string
+	expected string
 }
 
 func TestCompletion(t *testing.T) {
 	test := assert.New(t)
 
+	for _, testcase := range getTestcases() {
+		id, candidates, err := getTestcaseCandidates(testcase)
+		if err != nil {
+			test.Errorf(err, "unable to get completion candidates: %s", testcase.path)
+			continue
+		}
+
+		if !test.NotNilf(id, "invalid prompt (identifier = nil) in %s", testcase.path) {
+			continue
+		}
+
+		values := []string{}
+		for _, candidate := range candidates {
+			values = append(values, candidate.Value)
+
+			log.Printf("candidate=%s y=%d x=%d", candidate.Value, candidate.Y, candidate.X)
+		}
+
+		test.EqualValues(testcase.expected, values, "%s", testcase.path)
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	test := assert.New(t)
+
+	keymap, err := NewKeymap(nil)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, testcase := range getTestcases() {
+		id, candidates, err := getTestcaseCandidates(testcase)
+		if err != nil || id == nil {
+			// reported by TestCompletion
+			continue
+		}
+
+		picker := &Picker{
+			lines:      testcase.lines,
+			keymap:     keymap,
+			identifier: id,
+			candidates: candidates,
+		}
+
+		selectDefaultCandidate(candidates, id.X, id.Y)
+
+		for i, step := range testcase.steps {
+			if step.key != "-" {
+				events, err := getKeyEvents(step.key)
+				if !test.NoError(err, "%s: step %d", testcase.path, i+1) {
+					break
+				}
+
+				_, err = picker.process(events[0])
+				test.NoError(err)
+			}
+
+			selected := getSelectedCandidate(candidates)
+			if !test.NotNil(selected, "%s: step %d", testcase.path, i+1) {
+				break
+			}
+
+			test.Equal(
+				step.expected, selected.Value,
+				"%s: step %d: %s", testcase.path, i+1, step.key,
+			)
+		}
+	}
+}
+
+func getTestcases() []testcase {
 	scenarios, err := filepath.Glob("unit_tests/*")
 	if err != nil {
 		panic(err)
@@ -46,39 +127,26 @@ func TestCompletion(t *testing.T) {
 		panic("no tests found")
 	}
 
-	for _, testcase := range testcases {
-		id, err := getIdentifierToComplete(
-			defaultRegexpCursor,
-			testcase.lines,
-			testcase.cursorX,
-			testcase.cursorY,
-		)
-		if err != nil {
-			test.Errorf(err, "unable to get completion identifier: %s", testcase.path)
-			continue
-		}
-
-		if !test.NotNilf(id, "invalid prompt (identifier = nil) in %s", testcase.path) {
-			continue
-		}
-
-		candidates, err := getCompletionCandidates(defaultRegexpCandidate, testcase.lines, id)
-		if err != nil {
-			test.Errorf(err, "unable to get completion candidates: %s", testcase.path)
-			continue
-		}
-
-		candidates = getUniqueCandidates(candidates)
-
-		values := []string{}
-		for _, candidate := range candidates {
-			values = append(values, candidate.Value)
+	return testcases
+}
 
-			log.Printf("candidate=%s y=%d x=%d", candidate.Value, candidate.Y, candidate.X)
-		}
+func getTestcaseCandidates(testcase testcase) (*Identifier, []*Candidate, error) {
+	id, err := getIdentifierToComplete(
+		defaultRegexpCursor,
+		testcase.lines,
+		testcase.cursorX,
+		testcase.cursorY,
+	)
+	if err != nil || id == nil {
+		return id, nil, err
+	}
 
-		test.EqualValues(testcase.expected, values, "%s", testcase.path)
+	candidates, err := getCompletionCandidates(defaultRegexpCandidate, testcase.lines, id)
+	if err != nil {
+		return id, nil, err
 	}
+
+	return id, getUniqueCandidates(candidates), nil
 }
 
 func getTestcase(filename string) testcase {
@@ -93,6 +161,29 @@ func getTestcase(filename string) testcase {
 	prompt := src[1]
 	candidates := src[2:]
 
+	// navigation steps are optional and go after candidates:
+	// ---
+	// - <default selected value>
+	// <key> <selected value>
+	steps := []step{}
+	for i, line := range candidates {
+		if line != "---" {
+			continue
+		}
+
+		for _, line := range candidates[i+1:] {
+			fields := strings.SplitN(line, " ", 2)
+			if len(fields) != 2 {
+				panic("invalid navigation step in " + filename + ": " + line)
+			}
+
+			steps = append(steps, step{key: fields[0], expected: fields[1]})
+		}
+
+		candidates = candidates[:i]
+		break
+	}
+
 	// no cache because zfs already has arc
 	paneData, err := ioutil.ReadFile("unit_tests/" + pane)
 	if err != nil {
@@ -112,6 +203,7 @@ func getTestcase(filename string) testcase {
 		cursorX:  cursorX,
 		cursorY:  cursorY,
 		expected: candidates,
+		steps:    steps,
 	}
 }
 
@@ -119,3 +211,116 @@ func isFileExists(path string) bool {
 	stat, err := os.Stat(path)
 	return !os.IsNotExist(err) && !stat.IsDir()
 }
+
+func TestGetIdentifierToCompletePadding(t *testing.T) {
+	test := assert.New(t)
+
+	identifier, err := getIdentifierToComplete(defaultRegexpCursor, []string{"$ foo"}, 7, 0)
+	test.NoError(err)
+	test.Nil(identifier)
+
+	// cursor can't be further than the widest pane
+	identifier, err = getIdentifierToComplete(defaultRegexpCursor, []string{"$ foo"}, 1<<40, 0)
+	test.NoError(err)
+	test.Nil(identifier)
+}
+
+func TestCandidateType(t *testing.T) {
+	test := assert.New(t)
+
+	tests := []struct {
+		value  string
+		target string
+		kind   string
+	}{
+		{"https://github.com", "", candidateTypeURL},
+		{"README.md", "file:///tmp/README.md", candidateTypeURL},
+		{"./main.go", "", candidateTypePath},
+		{"src/main.go", "", candidateTypePath},
+		{"deadbeef1", "", candidateTypeHash},
+		{"deadbeef", "", candidateTypeWord},
+		{"42", "", candidateTypeNumber},
+		{"-3.14", "", candidateTypeNumber},
+		{"1,000", "", candidateTypeNumber},
+		{"127.0.0.1", "", candidateTypeWord},
+		{"v1.2.3", "", candidateTypeWord},
+		{"1.2.3", "", candidateTypeWord},
+		{"foo", "", candidateTypeWord},
+	}
+
+	for _, testcase := range tests {
+		candidate := &Candidate{
+			Identifier: &Identifier{Value: testcase.value},
+			Target:     testcase.target,
+		}
+
+		test.Equal(testcase.kind, candidate.Type(), testcase.value)
+	}
+}
+
+func FuzzGetIdentifierToComplete(f *testing.F) {
+	f.Add("foo bar\n$ ba", 4, 1)
+	f.Add("привет\n$ пр", 4, 1)
+	f.Add("", 0, 0)
+	f.Add("$ foo", 10, 0)
+
+	f.Fuzz(func(t *testing.T, contents string, x int, y int) {
+		// lines are always printable pane contents
+		lines := (&Pane{Lines: strings.Split(contents, "\n")}).GetPrintable()
+
+		// cursor is never far from the end of line, large values would only
+		// make fuzzer allocate padding
+		x %= 512
+
+		identifier, err := getIdentifierToComplete(defaultRegexpCursor, lines, x, y)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if identifier == nil {
+			return
+		}
+
+		symbols := []rune(lines[identifier.Y])
+		if identifier.X < 0 || identifier.X+identifier.Length() > len(symbols) ||
+			string(symbols[identifier.X:identifier.X+identifier.Length()]) != identifier.Value {
+			t.Fatalf("identifier %#v doesn't match line %q", identifier, lines[identifier.Y])
+		}
+	})
+}
+
+func FuzzGetCompletionCandidates(f *testing.F) {
+	f.Add("foo (foo.bar) fooqux:\n$ fo", "fo")
+	f.Add("127.0.0.1: привет", "")
+	f.Add("\xff\xfe foo", "f")
+
+	f.Fuzz(func(t *testing.T, contents string, prefix string) {
+		// such prefix can't be compiled into regexp
+		if !utf8.ValidString(prefix) {
+			return
+		}
+
+		// lines are always printable pane contents
+		lines := (&Pane{Lines: strings.Split(contents, "\n")}).GetPrintable()
+
+		candidates, err := getCompletionCandidates(
+			defaultRegexpCandidate,
+			lines,
+			&Identifier{X: -1, Y: -1, Value: prefix},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		candidates = getUniqueCandidates(candidates)
+		selectDefaultCandidate(candidates, 0, len(lines)-1)
+
+		for _, candidate := range candidates {
+			symbols := []rune(lines[candidate.Y])
+			if candidate.X < 0 || candidate.X > len(symbols) ||
+				!strings.HasPrefix(string(symbols[candidate.X:]), candidate.Value) {
+				t.Fatalf("candidate %#v doesn't match line %q", candidate, lines[candidate.Y])
+			}
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/kovetskiy/ko"
+	"github.com/reconquest/karma-go"
+	yaml "gopkg.in/coryb/yaml.v2"
+)
+
+var defaultConfigPath = `~/.config/tmux-autocomplete/config`
+
+type Config struct {
+	Keymap  map[string]string
+	Actions []Action
+
+	Clipboard Clipboard
+
+	Openers []Opener
+
+	Report ReportConfig
+
+	Theme ThemeConfig
+}
+
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+
+	err := ko.Load(expandHome(path), &config, yaml.Unmarshal, ko.RequireFile(false))
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to read config file: %s", path,
+		)
+	}
+
+	return &config, nil
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		// 1 because need to trim only ~ symbol, slash is required
+		return os.Getenv("HOME") + path[1:]
+	}
+
+	return path
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	pickerBuiltin = "builtin"
+	pickerFzf     = "fzf"
+)
+
+// pickWithFzf runs fzf in the current terminal and returns candidate chosen
+// by user or nil if nothing has been chosen. Candidates are listed starting
+// from the closest to the cursor.
+func pickWithFzf(
+	candidates []*Candidate,
+	lines []string,
+	x int,
+	y int,
+) (*Candidate, error) {
+	// preview shows pane contents scrolled to the line of candidate
+	preview, err := ioutil.TempFile("", "tmux-autocomplete_preview_")
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to create preview file",
+		)
+	}
+
+	defer os.Remove(preview.Name())
+
+	_, err = preview.WriteString(strings.Join(lines, "\n") + "\n")
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to write preview file",
+		)
+	}
+
+	err = preview.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([]*Candidate, len(candidates))
+	copy(ordered, candidates)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return getCandidateScore(ordered[i], x, y) >
+			getCandidateScore(ordered[j], x, y)
+	})
+
+	cmd := exec.Command(
+		"fzf",
+		"--delimiter", "\t",
+		"--with-nth", "3..",
+		"--no-sort",
+		"--preview", "cat "+shellQuote(preview.Name()),
+		"--preview-window", "+{2}-/2",
+	)
+	cmd.Stdin = getFzfInput(ordered)
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			// 1 means no match, 130 means fzf has been interrupted
+			if code := exit.ExitCode(); code == 1 || code == 130 {
+				return nil, nil
+			}
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to run fzf",
+		)
+	}
+
+	return parseFzfOutput(ordered, output)
+}
+
+// getFzfInput returns lines for fzf with index of candidate, line of
+// candidate for preview and candidate value. Value is the last field, because
+// it can contain tabs which are used as delimiter.
+func getFzfInput(candidates []*Candidate) *bytes.Buffer {
+	input := bytes.NewBuffer(nil)
+	seen := map[string]bool{}
+
+	for index, candidate := range candidates {
+		if seen[candidate.Value] {
+			continue
+		}
+
+		seen[candidate.Value] = true
+
+		fmt.Fprintf(input, "%d\t%d\t%s\n", index, candidate.Y+1, candidate.Value)
+	}
+
+	return input
+}
+
+// parseFzfOutput returns candidate which line has been chosen in fzf.
+func parseFzfOutput(candidates []*Candidate, output []byte) (*Candidate, error) {
+	fields := strings.SplitN(string(output), "\t", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected fzf output: %q", output)
+	}
+
+	index, err := strconv.Atoi(fields[0])
+	if err != nil || index < 0 || index >= len(candidates) {
+		return nil, fmt.Errorf("unexpected fzf output: %q", output)
+	}
+
+	return candidates[index], nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFzfInputOutput(t *testing.T) {
+	test := assert.New(t)
+
+	candidates := []*Candidate{
+		{Identifier: &Identifier{Value: "foo", Y: 0}},
+		{Identifier: &Identifier{Value: "bar\tbaz", Y: 2}},
+		{Identifier: &Identifier{Value: "foo", Y: 3}},
+		{Identifier: &Identifier{Value: "qux", Y: 4}},
+	}
+
+	test.Equal(
+		"0\t1\tfoo\n"+
+			"1\t3\tbar\tbaz\n"+
+			"3\t5\tqux\n",
+		getFzfInput(candidates).String(),
+	)
+
+	chosen, err := parseFzfOutput(candidates, []byte("1\t3\tbar\tbaz\n"))
+	test.NoError(err)
+	test.Equal(candidates[1], chosen)
+
+	chosen, err = parseFzfOutput(candidates, []byte("3\t5\tqux\n"))
+	test.NoError(err)
+	test.Equal(candidates[3], chosen)
+
+	_, err = parseFzfOutput(candidates, []byte("qux\n"))
+	test.Error(err)
+
+	_, err = parseFzfOutput(candidates, []byte("4\t5\tqux\n"))
+	test.Error(err)
+}
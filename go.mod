@@ -6,11 +6,12 @@ require (
 	github.com/docopt/docopt-go v0.0.0-20180111231733-ee0de3bc6815
 	github.com/kovetskiy/ko v1.6.1
 	github.com/mattn/go-isatty v0.0.20
-	github.com/mgutz/ansi v0.0.0-20200706080929-d51e80ef957d
+	github.com/mattn/go-runewidth v0.0.9
 	github.com/nsf/termbox-go v1.1.1
 	github.com/reconquest/executil-go v0.0.0-20181110204642-1f5c2d67813f
 	github.com/reconquest/karma-go v1.4.0
 	github.com/stretchr/testify v1.8.2
+	golang.org/x/term v0.19.0
 	gopkg.in/coryb/yaml.v2 v2.0.0
 )
 
@@ -18,8 +19,6 @@ require (
 	github.com/BurntSushi/toml v1.2.1 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/iancoleman/strcase v0.2.0 // indirect
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-runewidth v0.0.9 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/crypto v0.22.0 // indirect
 	golang.org/x/sys v0.19.0 // indirect
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const tmuxTestTimeout = 5 * time.Second
+
+var tmuxTestConfig = `
+set -g default-terminal screen-256color
+set -g status off
+set -sg escape-time 0
+`
+
+// tmuxServer is an isolated tmux server listening on private socket, so
+// tests don't interfere with the user's tmux. Server and programs started by
+// it get own HOME and TMPDIR, so user's config, caches and reports are not
+// touched.
+type tmuxServer struct {
+	t *testing.T
+
+	dir    string
+	socket string
+	binary string
+	env    []string
+}
+
+// startTmuxServer builds tmux-autocomplete and starts tmux server, test is
+// skipped if tmux is not installed.
+func startTmuxServer(t *testing.T) *tmuxServer {
+	if testing.Short() {
+		t.Skip("integration test")
+	}
+
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not installed")
+	}
+
+	dir := t.TempDir()
+
+	build := exec.Command("go", "build", "-o", filepath.Join(dir, "tmux-autocomplete"), ".")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("unable to build tmux-autocomplete: %s\n%s", err, output)
+	}
+
+	err := ioutil.WriteFile(filepath.Join(dir, "tmux.conf"), []byte(tmuxTestConfig), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	home := filepath.Join(dir, "home")
+
+	err = os.MkdirAll(filepath.Join(home, "tmp"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &tmuxServer{
+		t:      t,
+		dir:    dir,
+		socket: fmt.Sprintf("tmux-autocomplete-test-%d-%s", os.Getpid(), t.Name()),
+		binary: filepath.Join(dir, "tmux-autocomplete"),
+		env: append(
+			os.Environ(),
+			"HOME="+home,
+			"TMPDIR="+filepath.Join(home, "tmp"),
+			"XDG_RUNTIME_DIR=",
+		),
+	}
+
+	t.Cleanup(func() {
+		exec.Command("tmux", "-L", server.socket, "kill-server").Run()
+	})
+
+	return server
+}
+
+func (server *tmuxServer) tmux(args ...string) string {
+	args = append([]string{"-L", server.socket, "-f", filepath.Join(server.dir, "tmux.conf")}, args...)
+
+	cmd := exec.Command("tmux", args...)
+	cmd.Env = server.env
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		server.t.Fatalf("tmux %q: %s\n%s", args, err, output)
+	}
+
+	return string(output)
+}
+
+// spawn creates session with pane that shows given contents, cursor is left
+// at the end of contents and pasted text is echoed after it.
+func (server *tmuxServer) spawn(contents string) string {
+	path := filepath.Join(server.dir, "contents")
+
+	err := ioutil.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		server.t.Fatal(err)
+	}
+
+	pane := strings.TrimSpace(server.tmux(
+		"new-session", "-d", "-P", "-F", "#{pane_id}", "-x", "40", "-y", "10",
+		"sh -c 'cat "+path+"; exec cat'",
+	))
+
+	server.wait(pane, func(screen string) bool {
+		// capture-pane trims trailing spaces
+		return strings.HasSuffix(
+			strings.TrimRight(screen, "\n"),
+			strings.TrimRight(lastLine(contents), " "),
+		)
+	})
+
+	return pane
+}
+
+// autocomplete runs tmux-autocomplete in given pane like key binding does
+// and waits until picker is shown.
+func (server *tmuxServer) autocomplete(pane string, args ...string) {
+	args = append(
+		[]string{
+			server.binary,
+			"--theme-path", filepath.Join(server.wd(), "share", "themes"),
+			"--config", filepath.Join(server.dir, "config"),
+		},
+		args...,
+	)
+
+	server.tmux("run-shell", "-b", "-t", pane, strings.Join(args, " "))
+
+	server.waitWindows(2)
+
+	// keys sent before picker reads terminal can be lost, so picker should
+	// draw pane contents first
+	for _, picker := range strings.Fields(server.tmux("list-panes", "-a", "-F", "#{pane_id}")) {
+		if picker != pane {
+			server.wait(picker, func(screen string) bool {
+				return strings.TrimSpace(screen) != ""
+			})
+		}
+	}
+}
+
+func (server *tmuxServer) wd() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		server.t.Fatal(err)
+	}
+
+	return wd
+}
+
+func (server *tmuxServer) sendKeys(keys ...string) {
+	for _, key := range keys {
+		server.tmux("send-keys", key)
+	}
+}
+
+func (server *tmuxServer) capture(pane string) string {
+	return server.tmux("capture-pane", "-p", "-t", pane)
+}
+
+func (server *tmuxServer) wait(pane string, done func(screen string) bool) string {
+	deadline := time.Now().Add(tmuxTestTimeout)
+
+	for {
+		screen := server.capture(pane)
+		if done(screen) {
+			return screen
+		}
+
+		if time.Now().After(deadline) {
+			server.t.Fatalf("timeout waiting for pane %s:\n%s", pane, screen)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (server *tmuxServer) waitWindows(count int) {
+	deadline := time.Now().Add(tmuxTestTimeout)
+
+	for {
+		windows := strings.Fields(server.tmux("list-windows", "-a", "-F", "#{window_id}"))
+		if len(windows) == count {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			server.t.Fatalf("timeout waiting for %d windows, got %d", count, len(windows))
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func lastLine(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	return lines[len(lines)-1]
+}
+
+func TestTmuxPasteSelectedCandidate(t *testing.T) {
+	test := assert.New(t)
+
+	server := startTmuxServer(t)
+
+	pane := server.spawn("foo1 foo2\nfoo3\n$ fo")
+
+	server.autocomplete(pane)
+	server.sendKeys("k", "l", "Enter")
+	server.waitWindows(1)
+
+	screen := server.wait(pane, func(screen string) bool {
+		return strings.Contains(screen, "$ foo")
+	})
+
+	test.Contains(screen, "$ foo2")
+}
+
+func TestTmuxCancel(t *testing.T) {
+	test := assert.New(t)
+
+	server := startTmuxServer(t)
+
+	pane := server.spawn("foo1 foo2\n$ fo")
+
+	server.autocomplete(pane)
+	server.sendKeys("Escape")
+	server.waitWindows(1)
+
+	test.Equal("foo1 foo2\n$ fo", strings.TrimRight(server.capture(pane), "\n"))
+}
+
+func TestTmuxCopyToBuffer(t *testing.T) {
+	test := assert.New(t)
+
+	server := startTmuxServer(t)
+
+	err := ioutil.WriteFile(
+		filepath.Join(server.dir, "config"),
+		[]byte("clipboard:\n    buffer: autocomplete\n"),
+		0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pane := server.spawn("http://example.com/foo http://example.com/bar\n$ ")
+
+	server.autocomplete(pane, "--url")
+	server.sendKeys("h", "y")
+	server.waitWindows(1)
+
+	test.Equal(
+		"http://example.com/foo",
+		server.tmux("show-buffer", "-b", "autocomplete"),
+	)
+}
@@ -3,6 +3,7 @@
 tests:clone "../tmux-autocomplete" "bin/tests-tmux-autocomplete"
 tests:clone "bin/print-data" "bin/"
 tests:clone "bin/signal" "bin/"
+tests:clone "bin/clipboard-stub" "bin/"
 tests:clone "bash.rc" "."
 tests:clone "tmux.conf" "."
 
@@ -52,7 +53,7 @@ tests:clone "tmux.conf" "."
 
 :tmux-complete() {
     coproc:run ta \
-        :tmux run $(tests:get-tmp-dir)/bin/tests-tmux-autocomplete --debug /tmp/debug
+        :tmux run "$(tests:get-tmp-dir)/bin/tests-tmux-autocomplete --debug /tmp/debug ${*}"
 
     coproc:get-stdin-fd $ta stdin
     # doesn't work without these calls, the process will not start.
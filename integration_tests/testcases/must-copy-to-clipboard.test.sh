@@ -0,0 +1,24 @@
+#!/bin/bash
+
+tests:put clipboard.config <<CONFIG
+clipboard:
+    buffer: autocomplete
+    command: $(tests:get-tmp-dir)/bin/clipboard-stub
+CONFIG
+
+:tmux-start
+:tmux-new
+:tmux-sh print-data
+:tmux-wait
+
+:tmux-type "D"
+:tmux-complete --config $(tests:get-tmp-dir)/clipboard.config
+:tmux-type "k"
+:tmux-type "y"
+:tmux-complete-wait
+
+tests:eval cat $(tests:get-tmp-dir)/clipboard
+tests:assert-stdout "D333"
+
+tests:eval :tmux show-buffer -b autocomplete
+tests:assert-stdout "D333"
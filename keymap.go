@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	actionSelectUp    = "select-up"
+	actionSelectDown  = "select-down"
+	actionSelectLeft  = "select-left"
+	actionSelectRight = "select-right"
+	actionSelectNext  = "select-next"
+	actionSelectPrev  = "select-prev"
+	actionSelectFirst = "select-first"
+	actionSelectLast  = "select-last"
+	actionAccept      = "accept"
+	actionMenu        = "menu"
+	actionCancel      = "cancel"
+	actionToggleView  = "toggle-view"
+	actionMark        = "mark"
+	actionCopy        = "copy"
+	actionSearch      = "search"
+	actionSearchNext  = "search-next"
+	actionSearchPrev  = "search-prev"
+
+	// actionNone can be used in config to unbind default key
+	actionNone = "none"
+)
+
+var keymapActions = []string{
+	actionSelectUp,
+	actionSelectDown,
+	actionSelectLeft,
+	actionSelectRight,
+	actionSelectNext,
+	actionSelectPrev,
+	actionSelectFirst,
+	actionSelectLast,
+	actionAccept,
+	actionMenu,
+	actionCancel,
+	actionToggleView,
+	actionMark,
+	actionCopy,
+	actionSearch,
+	actionSearchNext,
+	actionSearchPrev,
+	actionNone,
+}
+
+var defaultKeymap = map[string]string{
+	"k":  actionSelectUp,
+	"Up": actionSelectUp,
+
+	"j":    actionSelectDown,
+	"Down": actionSelectDown,
+
+	"h":    actionSelectLeft,
+	"Left": actionSelectLeft,
+
+	"l":     actionSelectRight,
+	"Right": actionSelectRight,
+
+	"Tab": actionSelectNext,
+	"g":   actionSelectFirst,
+	"G":   actionSelectLast,
+
+	"Enter":  actionAccept,
+	"a":      actionMenu,
+	"C-c":    actionCancel,
+	"Escape": actionCancel,
+	"q":      actionCancel,
+
+	"v":     actionToggleView,
+	"Space": actionMark,
+	"y":     actionCopy,
+
+	"/": actionSearch,
+	"n": actionSearchNext,
+	"N": actionSearchPrev,
+}
+
+// key names are the same as tmux uses in bind-key and send-keys
+var keyNames = map[string]termbox.Key{
+	"Up":     termbox.KeyArrowUp,
+	"Down":   termbox.KeyArrowDown,
+	"Left":   termbox.KeyArrowLeft,
+	"Right":  termbox.KeyArrowRight,
+	"Enter":  termbox.KeyEnter,
+	"Escape": termbox.KeyEsc,
+	"Tab":    termbox.KeyTab,
+	"Space":  termbox.KeySpace,
+	"BSpace": termbox.KeyBackspace2,
+	"Home":   termbox.KeyHome,
+	"End":    termbox.KeyEnd,
+	"PPage":  termbox.KeyPgup,
+	"NPage":  termbox.KeyPgdn,
+	"DC":     termbox.KeyDelete,
+	"IC":     termbox.KeyInsert,
+}
+
+type Key struct {
+	Key termbox.Key
+	Ch  rune
+	Mod termbox.Modifier
+}
+
+type Keymap map[Key]string
+
+func getKey(event termbox.Event) Key {
+	mod := event.Mod & termbox.ModAlt
+
+	if event.Ch != 0 {
+		return Key{Ch: event.Ch, Mod: mod}
+	}
+
+	return Key{Key: event.Key, Mod: mod}
+}
+
+func parseKey(name string) (Key, error) {
+	// M- is Alt (Meta) modifier, e.g. M-x or M-Up
+	if strings.HasPrefix(name, "M-") && len(name) > 2 {
+		key, err := parseKey(name[2:])
+		if err != nil {
+			return Key{}, fmt.Errorf("unknown key: %q", name)
+		}
+
+		key.Mod = termbox.ModAlt
+
+		return key, nil
+	}
+
+	if key, ok := keyNames[name]; ok {
+		return Key{Key: key}, nil
+	}
+
+	if strings.HasPrefix(name, "C-") && len(name) == 3 {
+		symbol := name[2]
+		if symbol >= 'A' && symbol <= 'Z' {
+			symbol += 'a' - 'A'
+		}
+
+		if symbol >= 'a' && symbol <= 'z' {
+			return Key{Key: termbox.KeyCtrlA + termbox.Key(symbol-'a')}, nil
+		}
+	}
+
+	if symbols := []rune(name); len(symbols) == 1 {
+		return Key{Ch: symbols[0]}, nil
+	}
+
+	return Key{}, fmt.Errorf("unknown key: %q", name)
+}
+
+func isKnownAction(action string) bool {
+	for _, known := range keymapActions {
+		if known == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewKeymap returns default keymap with bindings from given map applied on
+// top of it.
+func NewKeymap(bindings map[string]string) (Keymap, error) {
+	keymap := Keymap{}
+
+	for _, source := range []map[string]string{defaultKeymap, bindings} {
+		for name, action := range source {
+			key, err := parseKey(name)
+			if err != nil {
+				return nil, err
+			}
+
+			if !isKnownAction(action) {
+				return nil, fmt.Errorf(
+					"unknown action %q bound to key %q", action, name,
+				)
+			}
+
+			if action == actionNone {
+				delete(keymap, key)
+				continue
+			}
+
+			keymap[key] = action
+		}
+	}
+
+	return keymap, nil
+}
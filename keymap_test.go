@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKey(t *testing.T) {
+	test := assert.New(t)
+
+	for name, expected := range map[string]Key{
+		"C-c":    {Key: termbox.KeyCtrlC},
+		"C-R":    {Key: termbox.KeyCtrlR},
+		"M-x":    {Ch: 'x', Mod: termbox.ModAlt},
+		"M-Up":   {Key: termbox.KeyArrowUp, Mod: termbox.ModAlt},
+		"M-C-c":  {Key: termbox.KeyCtrlC, Mod: termbox.ModAlt},
+		"Escape": {Key: termbox.KeyEsc},
+		"Space":  {Key: termbox.KeySpace},
+		"Tab":    {Key: termbox.KeyTab},
+		"q":      {Ch: 'q'},
+		"ж":      {Ch: 'ж'},
+	} {
+		key, err := parseKey(name)
+		test.NoError(err, name)
+		test.Equal(expected, key, name)
+	}
+
+	for _, name := range []string{"", "C-1", "M-", "M-Foo", "Foo", "F13"} {
+		_, err := parseKey(name)
+		test.Error(err, name)
+	}
+}
+
+func TestNewKeymap(t *testing.T) {
+	test := assert.New(t)
+
+	keymap, err := NewKeymap(map[string]string{
+		"q":   actionNone,
+		"J":   actionSelectNext,
+		"M-x": actionCopy,
+		"Tab": actionSelectPrev,
+	})
+	test.NoError(err)
+
+	test.NotContains(keymap, Key{Ch: 'q'})
+	test.Equal(actionSelectNext, keymap[Key{Ch: 'J'}])
+	test.Equal(actionCopy, keymap[Key{Ch: 'x', Mod: termbox.ModAlt}])
+	test.Equal(actionSelectPrev, keymap[Key{Key: termbox.KeyTab}])
+	test.Equal(actionCancel, keymap[Key{Key: termbox.KeyEsc}])
+
+	_, err = NewKeymap(map[string]string{"Foo": actionAccept})
+	test.EqualError(err, `unknown key: "Foo"`)
+
+	_, err = NewKeymap(map[string]string{"x": "explode"})
+	test.EqualError(err, `unknown action "explode" bound to key "x"`)
+}
+
+func TestPollEvents(t *testing.T) {
+	test := assert.New(t)
+
+	var (
+		escape = termbox.Event{Type: termbox.EventKey, Key: termbox.KeyEsc}
+		x      = termbox.Event{Type: termbox.EventKey, Ch: 'x'}
+		resize = termbox.Event{Type: termbox.EventResize}
+	)
+
+	polled := make(chan termbox.Event, 10)
+	events := pollEvents(func() termbox.Event { return <-polled })
+
+	// Escape immediately followed by key is Alt combination
+	polled <- escape
+	polled <- x
+	test.Equal(termbox.Event{Type: termbox.EventKey, Ch: 'x', Mod: termbox.ModAlt}, <-events)
+
+	// double Escape and Escape followed by non-key event are kept
+	polled <- escape
+	polled <- escape
+	polled <- resize
+	test.Equal(escape, <-events)
+	test.Equal(escape, <-events)
+	test.Equal(resize, <-events)
+
+	// lone Escape is reported after a while
+	polled <- escape
+	test.Equal(escape, <-events)
+
+	time.Sleep(2 * escapeTime)
+
+	polled <- x
+	test.Equal(x, <-events)
+}
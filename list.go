@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/reconquest/karma-go"
+)
+
+type listedCandidate struct {
+	Value  string  `json:"value"`
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Parent string  `json:"parent,omitempty"`
+	Target string  `json:"target,omitempty"`
+	Type   string  `json:"type"`
+	Score  float64 `json:"score"`
+}
+
+// list prints candidates found in the pane or in the file without starting
+// picker.
+func list(args map[string]interface{}, tmux *Tmux) error {
+	return listCandidates(args, tmux, os.Stdin, os.Stdout)
+}
+
+func listCandidates(
+	args map[string]interface{},
+	tmux *Tmux,
+	stdin io.Reader,
+	stdout io.Writer,
+) error {
+	pane, x, y, err := getListPane(args, tmux, stdin)
+	if err != nil {
+		return err
+	}
+
+	lines := pane.GetPrintable()
+
+	_, candidates, err := getCandidates(args, pane, lines, x, y)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetEscapeHTML(false)
+
+	for _, candidate := range candidates {
+		if !args["--json"].(bool) {
+			fmt.Fprintln(stdout, candidate.Value)
+			continue
+		}
+
+		err := encoder.Encode(listedCandidate{
+			Value:  candidate.Value,
+			X:      candidate.X,
+			Y:      candidate.Y,
+			Parent: candidate.Parent,
+			Target: candidate.Target,
+			Type:   candidate.Type(),
+			Score:  getCandidateScore(candidate, x, y),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getListPane returns pane to search candidates in and cursor position in
+// buffer coordinates, - file is read from stdin.
+func getListPane(
+	args map[string]interface{},
+	tmux *Tmux,
+	stdin io.Reader,
+) (*Pane, int, int, error) {
+	path, ok := args["<file>"].(string)
+	if !ok {
+		var (
+			id      string
+			cursorX int
+			cursorY int
+		)
+
+		err := tmux.Eval(
+			map[string]interface{}{
+				"pane_id":  &id,
+				"cursor_x": &cursorX,
+				"cursor_y": &cursorY,
+			},
+		)
+		if err != nil {
+			return nil, 0, 0, karma.Format(
+				err,
+				"unable to get current pane/cursor",
+			)
+		}
+
+		pane, err := CapturePane(tmux, id, "-eJ")
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		x, y := pane.GetBufferXY(pane.GetPrintable(), cursorX, cursorY)
+
+		return pane, x, y, nil
+	}
+
+	var (
+		contents []byte
+		err      error
+	)
+
+	if path == "-" {
+		contents, err = ioutil.ReadAll(stdin)
+	} else {
+		contents, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, 0, 0, karma.Format(
+			err,
+			"unable to read pane contents: %s", path,
+		)
+	}
+
+	pane := &Pane{
+		Lines: strings.Split(strings.TrimRight(string(contents), "\n"), "\n"),
+	}
+
+	// lines of file are not wrapped, so buffer and screen coordinates are
+	// the same
+	lines := pane.GetPrintable()
+	y := len(lines) - 1
+	x := len([]rune(lines[y]))
+
+	return pane, x, y, nil
+}
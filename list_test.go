@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docopt/docopt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	test := assert.New(t)
+
+	contents := "64 bytes from 127.0.0.1: icmp_seq=1\n$ ping 12\n"
+
+	path := filepath.Join(t.TempDir(), "ping.log")
+
+	err := ioutil.WriteFile(path, []byte(contents), 0644)
+	test.NoError(err)
+
+	tests := []struct {
+		argv   []string
+		stdin  string
+		output string
+	}{
+		{
+			argv:   []string{"list", path},
+			output: "127.0.0.1:\n127.0.0.1\n",
+		},
+		{
+			argv:   []string{"list", "-"},
+			stdin:  contents,
+			output: "127.0.0.1:\n127.0.0.1\n",
+		},
+		{
+			argv:   []string{"list", "--no-prefix", "-r", "[0-9.]+", path},
+			output: "64\n127.0.0.1\n1\n12\n",
+		},
+		{
+			argv:  []string{"list", "--json", "--no-prefix", "-r", "[0-9.]+", "-"},
+			stdin: "ping 127.0.0.1 42\n",
+			output: `{"value":"127.0.0.1","x":5,"y":0,"type":"word","score":0.9881422924901185}` + "\n" +
+				`{"value":"42","x":15,"y":0,"type":"number","score":0.998003992015968}` + "\n",
+		},
+	}
+
+	parser := &docopt.Parser{HelpHandler: docopt.NoHelpHandler}
+
+	for _, testcase := range tests {
+		args, err := parser.ParseArgs(usage, testcase.argv, "")
+		test.NoError(err)
+
+		var output bytes.Buffer
+
+		err = listCandidates(
+			args,
+			&Tmux{},
+			strings.NewReader(testcase.stdin),
+			&output,
+		)
+		test.NoError(err, "%q", testcase.argv)
+		test.Equal(testcase.output, output.String(), "%q", testcase.argv)
+	}
+}
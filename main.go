@@ -3,16 +3,15 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/docopt/docopt-go"
 	"github.com/mattn/go-isatty"
-	"github.com/mgutz/ansi"
-	"github.com/nsf/termbox-go"
-	"github.com/reconquest/executil-go"
 	"github.com/reconquest/karma-go"
 )
 
@@ -32,6 +31,11 @@ Usage:
   tmux-autocomplete -h | --help
   tmux-autocomplete [options]
   tmux-autocomplete [options] -W <pane> <cursor-x> <cursor-y>
+  tmux-autocomplete [options] list [--json] [<file>]
+  tmux-autocomplete [options] complete [--window] [--] [<word>]
+  tmux-autocomplete [options] replay <file>
+  tmux-autocomplete [options] themes list
+  tmux-autocomplete [options] themes check <file>
 
 Options:
   -c --regexp-cursor <regexp>     Identifier regexp to match.
@@ -39,15 +43,65 @@ Options:
   -r --regexp-candidate <regexp>  Candidate regexp to match.
                                    [default: ` + defaultRegexpCandidate + `]
   -n --no-prefix                  Don't use identifier under cursor as prefix.
-  -e --exec <program>             Exec specified program and pass specified candidate as argument.
-  --theme <name>                  Name of theme to use. [default: light]
+  -u --url                        Complete URLs and OSC 8 hyperlinks and open
+                                   selected one, see openers in config.
+  -e --exec <command>             Exec specified command with selected candidate.
+                                   Following placeholders can be used in command:
+                                   {value}, {prefix}, {pane_id}, {pane_current_path},
+                                   {line}, {x}, {y}, {type} and {target}. If there
+                                   are no placeholders, command is a program and
+                                   candidate is passed as its argument.
+                                   Values are also exported as TMUX_AUTOCOMPLETE_*
+                                   environment variables.
+  --exec-cwd                      Exec command in the working directory of pane.
+  --exec-paste                    Paste output of command instead of candidate.
+  -p --picker <name>              Picker to use: builtin or fzf, fzf lists unique
+                                   candidates with preview of pane contents.
+                                   [default: ` + pickerBuiltin + `]
+  -s --status-line <position>     Show status line at top or bottom of pane,
+                                   can be top, bottom or none. [default: none]
+  --config <path>                 Path to config file.
+                                   [default: ` + defaultConfigPath + `]
+  --theme <name>                  Name of theme to use, auto chooses light or
+                                   dark theme by background of terminal.
+                                   [default: light]
   --theme-path <dir>              Path to directories with themes. Default:
                                    * ` + defaultSystemThemePath + `
                                    * ` + defaultUserThemePath + `
                                    You can specify multiple directories using : separator.
+  --colors <mode>                 Colors supported by terminal: 16, 256,
+                                   truecolor or auto to detect them using
+                                   $COLORTERM and tmux terminal features.
+                                   [default: auto]
+  --keys <keys>                   Replay specified keys without starting picker,
+                                   e.g. "j j l Enter", and print selection.
   --debug <file>                  Print debug messages into specified file.
+  --bug-report                    Write bug report after picker exits, use it
+                                   when wrong candidate is selected or found.
   -v --version                    Print version.
   -h --help                       Show this help.
+
+Commands:
+  list                            Print candidates without starting picker,
+                                   candidates are searched in the current pane
+                                   or in specified file (- for stdin), cursor
+                                   is assumed to be at the end of file.
+                                   Score is between 0 and 1, closer candidates
+                                   have higher score.
+    --json                        Print candidates as JSON lines.
+  complete                        Print candidates starting with specified word,
+                                   it's used by shell completion, see
+                                   share/completion.
+    --window                      Search candidates in all panes of window.
+  replay                          Run picker against pane contents from bug
+                                   report or file made by capture-pane -e,
+                                   tmux is not required. Action is not
+                                   performed, selected candidate is printed.
+                                   Options recorded in bug report are used
+                                   unless they are specified.
+  themes list                     List themes found in --theme-path and themes
+                                   built into binary.
+  themes check                    Check colors and keys of specified theme file.
 `
 
 var debug = log.New(ioutil.Discard, "", 0)
@@ -72,28 +126,138 @@ func main() {
 
 		defer out.Close()
 
-		debug = log.New(out, "", log.Lshortfile|log.Ltime)
+		debug = log.New(io.MultiWriter(out, debugLog), "", log.Lshortfile|log.Ltime)
+	} else {
+		debug = log.New(debugLog, "", log.Lshortfile|log.Ltime)
 	}
 
-	themePath, ok := args["--theme-path"].(string)
-	if !ok {
-		themePath = defaultThemePath
+	var replayed *replayedPane
+	if args["replay"].(bool) {
+		replayed, err = loadReplayFile(args["<file>"].(string))
+		if err != nil {
+			fatalln(err, 2)
+		}
+
+		err = applyReplayedArgs(args, replayed.Args, os.Args[1:])
+		if err != nil {
+			fatalln(err, 2)
+		}
+	}
+
+	report.Args = args
+
+	// themes list is checked first, because list is also a command
+	if args["themes"].(bool) {
+		err := themes(args)
+		if err != nil {
+			fatalln(err, 1)
+		}
+
+		return
+	}
+
+	if args["list"].(bool) {
+		err := list(args, &Tmux{})
+		if err != nil {
+			fatalln(err, 1)
+		}
+
+		return
+	}
+
+	if args["complete"].(bool) {
+		err := complete(args, &Tmux{})
+		if err != nil {
+			// stdout is read by shell, errors should not become candidates
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	themePath := getThemePath(args)
+
+	report.Theme = args["--theme"].(string)
+	report.ThemePath = themePath
+
+	// auto theme is chosen in the picker window, because terminal is queried
+	var theme *Theme
+	if report.Theme != themeAuto {
+		theme, err = getTheme(themePath, report.Theme)
+		if err != nil {
+			fatalln(err, 2)
+		}
+	}
+
+	configPath := args["--config"].(string)
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		fatalln(err, 2)
+	}
+
+	keymap, err := NewKeymap(config.Keymap)
+	if err != nil {
+		fatalln(
+			karma.
+				Describe("path", configPath).
+				Format(err, "invalid keymap"),
+			2,
+		)
+	}
+
+	report.redactions, err = getRedactions(config.Report.Redact)
+	if err != nil {
+		fatalln(
+			karma.
+				Describe("path", configPath).
+				Format(err, "invalid report config"),
+			2,
+		)
 	}
 
-	theme, err := LoadTheme(themePath, args["--theme"].(string))
+	report.context = config.Report.Context
+
+	actions, err := getMenuActions(config.Actions)
 	if err != nil {
 		fatalln(
 			karma.
-				Describe("path", themePath).
-				Describe("theme", args["--theme"].(string)).
-				Format(err, "unable to load theme"),
+				Describe("path", configPath).
+				Format(err, "invalid actions"),
+			2,
+		)
+	}
+
+	statusLine := args["--status-line"].(string)
+	switch statusLine {
+	case statusLineNone, statusLineTop, statusLineBottom:
+	default:
+		fatalln(
+			fmt.Sprintf("unexpected status line position: %q", statusLine),
 			2,
 		)
 	}
 
+	colors := args["--colors"].(string)
+	switch colors {
+	case colorModeAuto, colorMode16, colorMode256, colorModeTruecolor:
+	default:
+		fatalln(fmt.Sprintf("unexpected colors: %q", colors), 2)
+	}
+
+	picker := args["--picker"].(string)
+	switch picker {
+	case pickerBuiltin, pickerFzf:
+	default:
+		fatalln(fmt.Sprintf("unexpected picker: %q", picker), 2)
+	}
+
 	tmux := &Tmux{}
 
-	if !args["-W"].(bool) {
+	replaying := args["replay"].(bool)
+	if replaying {
+		tmux = &Tmux{backend: replayed.exec}
+	} else if !args["-W"].(bool) {
 		if isatty.IsTerminal(os.Stdin.Fd()) {
 			printIntroductionMessage()
 			os.Exit(1)
@@ -113,25 +277,92 @@ func main() {
 		return
 	}
 
+	report.tmux = tmux
+
+	if report.Theme == themeAuto {
+		report.Theme, err = getAutoTheme(tmux, config.Theme)
+		if err != nil {
+			log.Fatalln(
+				karma.
+					Describe("path", configPath).
+					Format(err, "invalid theme config"),
+			)
+		}
+
+		theme, err = getTheme(themePath, report.Theme)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if colors == colorModeAuto {
+		colors = detectColorMode(tmux)
+	}
+
+	// palette colors are left to terminal, so its own palette is used
+	if colors == colorModeTruecolor && !theme.hasRGBColors() {
+		debug.Printf("colors: theme has no RGB colors, using 256 colors")
+		colors = colorMode256
+	}
+
+	colorMode = colors
+
 	var (
+		paneID  string
 		cursorX int
 		cursorY int
 
 		program, _ = args["--exec"].(string)
 		withPrefix = !args["--no-prefix"].(bool)
+
+		action = getDefaultAction(
+			program,
+			args["--exec-cwd"].(bool),
+			args["--exec-paste"].(bool),
+		)
+
+		pattern = getPatternType(
+			args["--regexp-cursor"].(string),
+			args["--regexp-candidate"].(string),
+		)
 	)
 
-	_, err = fmt.Sscan(args["<cursor-x>"].(string), &cursorX)
-	if err != nil {
-		log.Fatalln(err)
+	if args["--url"].(bool) {
+		// URL is never completed, it's opened instead
+		withPrefix = false
+		pattern = patternTypeURL
+
+		if program == "" {
+			action = Action{Name: "open", Type: actionTypeOpen}
+		}
 	}
 
-	_, err = fmt.Sscan(args["<cursor-y>"].(string), &cursorY)
-	if err != nil {
-		log.Fatalln(err)
+	if replaying {
+		err = tmux.Eval(
+			map[string]interface{}{
+				"pane_id":  &paneID,
+				"cursor_x": &cursorX,
+				"cursor_y": &cursorY,
+			},
+		)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	} else {
+		paneID = args["<pane>"].(string)
+
+		_, err = fmt.Sscan(args["<cursor-x>"].(string), &cursorX)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		_, err = fmt.Sscan(args["<cursor-y>"].(string), &cursorY)
+		if err != nil {
+			log.Fatalln(err)
+		}
 	}
 
-	pane, err := CapturePane(tmux, args["<pane>"].(string), "-eJ")
+	pane, err := CapturePane(tmux, paneID, "-eJ")
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -146,38 +377,30 @@ func main() {
 	report.Lines = lines
 
 	defer func() {
-		writeReport(recover())
-	}()
-
-	var identifier *Identifier
-	if withPrefix {
-		identifier, err = getIdentifierToComplete(args["--regexp-cursor"].(string), lines, x, y)
-		if err != nil {
-			log.Fatalln(err)
-		}
+		reason := recover()
+		if reason == nil && args["--bug-report"].(bool) {
+			err := writeRequestedReport()
+			if err != nil {
+				log.Fatalln(err)
+			}
 
-		if identifier == nil {
 			return
 		}
-	}
 
-	moveCursor(cursorX, cursorY)
+		writeReport(reason)
+	}()
 
-	candidates, err := getCompletionCandidates(
-		args["--regexp-candidate"].(string),
-		lines,
-		identifier,
-	)
+	identifier, candidates, err := getCandidates(args, pane, lines, x, y)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	report.candidates = candidates
+
 	if len(candidates) == 0 {
 		return
 	}
 
-	candidates = getUniqueCandidates(candidates)
-
 	if identifier == nil {
 		identifier = candidates[len(candidates)-1].Identifier
 	}
@@ -185,70 +408,192 @@ func main() {
 	selectDefaultCandidate(candidates, identifier.X, identifier.Y)
 
 	if len(candidates) == 1 {
-		useCurrentCandidate(
+		if replaying {
+			printReplayResult(candidates, &action)
+			return
+		}
+
+		err := useCurrentCandidate(
 			tmux,
 			pane,
 			identifier,
 			candidates,
-			program,
+			action,
 			withPrefix,
+			config,
 		)
+		if err != nil {
+			displayError(tmux, err)
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	if picker == pickerFzf && !replaying {
+		if _, err := exec.LookPath("fzf"); err != nil {
+			debug.Printf("fzf not found: %s", err)
+
+			err := tmux.DisplayMessage(
+				"tmux-autocomplete: fzf is not installed, using built-in picker",
+			)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		} else {
+			chosen, err := pickWithFzf(candidates, lines, x, y)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			if chosen == nil {
+				return
+			}
+
+			getSelectedCandidate(candidates).Selected = false
+			chosen.Selected = true
+
+			err = useCurrentCandidate(
+				tmux,
+				pane,
+				identifier,
+				candidates,
+				action,
+				withPrefix,
+				config,
+			)
+			if err != nil {
+				displayError(tmux, err)
+				log.Fatalln(err)
+			}
+
+			return
+		}
+	}
+
+	builtin := &Picker{
+		tmux:   tmux,
+		pane:   pane,
+		lines:  lines,
+		theme:  theme,
+		keymap: keymap,
+		config: config,
+
+		identifier: identifier,
+		candidates: candidates,
+
+		action:     action,
+		actions:    actions,
+		withPrefix: withPrefix,
+
+		pattern:    pattern,
+		statusLine: statusLine,
+	}
+
+	if !replaying {
+		err = builtin.Run()
+		if err != nil {
+			log.Fatalln(err)
+		}
 
 		return
 	}
 
-	err = termbox.Init()
+	builtin.dryRun = true
+
+	if keys, ok := args["--keys"].(string); ok {
+		events, err := getKeyEvents(keys)
+		if err != nil {
+			fatalln(err, 2)
+		}
+
+		err = builtin.Replay(events)
+	} else {
+		err = builtin.Run()
+	}
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	for {
-		renderPane(pane, theme)
-		renderIdentifier(lines, pane, theme, identifier)
-		renderCandidates(lines, pane, theme, candidates)
-
-		switch ev := termbox.PollEvent(); ev.Type {
-		case termbox.EventKey:
-			switch {
-			case ev.Ch == 'k':
-				fallthrough
-			case ev.Key == termbox.KeyArrowUp:
-				selectNextCandidate(candidates, 0, -1)
-
-			case ev.Ch == 'j':
-				fallthrough
-			case ev.Key == termbox.KeyArrowDown:
-				selectNextCandidate(candidates, 0, 1)
-
-			case ev.Ch == 'h':
-				fallthrough
-			case ev.Key == termbox.KeyArrowLeft:
-				selectNextCandidate(candidates, -1, 0)
-
-			case ev.Ch == 'l':
-				fallthrough
-			case ev.Key == termbox.KeyArrowRight:
-				selectNextCandidate(candidates, 1, 0)
-
-			case ev.Key == termbox.KeyEnter:
-				useCurrentCandidate(
-					tmux,
-					pane,
-					identifier,
-					candidates,
-					program,
-					withPrefix,
-				)
+	printReplayResult(candidates, builtin.used)
+}
 
-				return
+// getCandidates returns identifier under cursor and unique candidates found
+// in the pane according to specified flags.
+func getCandidates(
+	args map[string]interface{},
+	pane *Pane,
+	lines []string,
+	x int,
+	y int,
+) (*Identifier, []*Candidate, error) {
+	if args["--url"].(bool) {
+		return nil, getURLCandidates(lines, pane.GetHyperlinks()), nil
+	}
 
-			case ev.Key == termbox.KeyCtrlC:
-				return
-			}
+	var identifier *Identifier
+	if !args["--no-prefix"].(bool) {
+		var err error
 
-		case termbox.EventError:
-			log.Fatalln(ev.Err)
+		identifier, err = getIdentifierToComplete(
+			args["--regexp-cursor"].(string),
+			lines, x, y,
+		)
+		if err != nil {
+			return nil, nil, err
 		}
+
+		if identifier == nil {
+			return nil, nil, nil
+		}
+	}
+
+	candidates, err := getCompletionCandidates(
+		args["--regexp-candidate"].(string),
+		lines,
+		identifier,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	candidates = mergeHyperlinkCandidates(
+		candidates,
+		getHyperlinkCandidates(pane.GetHyperlinks(), identifier),
+	)
+
+	return identifier, getUniqueCandidates(candidates), nil
+}
+
+func getTheme(themePath string, name string) (*Theme, error) {
+	theme, err := LoadTheme(themePath, name)
+	if err != nil {
+		return nil, karma.
+			Describe("path", themePath).
+			Describe("theme", name).
+			Format(err, "unable to load theme")
+	}
+
+	return theme, nil
+}
+
+func getThemePath(args map[string]interface{}) string {
+	themePath, ok := args["--theme-path"].(string)
+	if !ok {
+		return defaultThemePath
+	}
+
+	return themePath
+}
+
+// displayError shows the first line of error in status line, because picker
+// window is already closed when action fails.
+func displayError(tmux *Tmux, err error) {
+	message := strings.SplitN(err.Error(), "\n", 2)[0]
+
+	err = tmux.DisplayMessage("tmux-autocomplete: " + message)
+	if err != nil {
+		debug.Printf("unable to display error: %s", err)
 	}
 }
 
@@ -360,167 +705,3 @@ func start(args map[string]interface{}, themePath string, tmux *Tmux) error {
 
 	return nil
 }
-
-func renderPane(pane *Pane, theme *Theme) {
-	moveCursor(0, 0)
-
-	fmt.Print(ansi.ColorCode(theme.Fog.Text))
-
-	text := reEscapeSequence.ReplaceAllStringFunc(
-		pane.String(),
-		func(sequence string) string {
-			return decolorize(sequence, theme)
-		},
-	)
-
-	fmt.Print(text)
-}
-
-func decolorize(sequence string, theme *Theme) string {
-	var (
-		matches = reEscapeSequence.FindStringSubmatch(sequence)
-		code    = matches[1]
-	)
-
-	switch code {
-	// 49 means reset background color to default,
-	// we remove background color and set dim color for foreground
-	case "49":
-		return ansi.DefaultBG + ansi.ColorCode(theme.Fog.Text)
-
-	// 39 means reset foreground color to default,
-	// 0 means reset all style to default,
-	// we reset style and set dim color for foreground
-	case "0", "39":
-		return ansi.Reset + ansi.ColorCode(theme.Fog.Text)
-
-	// 1 means bold,
-	// we remove bold
-	case "1":
-		return ""
-
-	// 7 means reverse,
-	// we set dim background color
-	case "7":
-		return ansi.ColorCode(theme.Fog.Background)
-
-	default:
-		// all single-number codes,
-		// we remove
-		if len(code) == 1 {
-			return ""
-		}
-
-		// all foreground theme,
-		// we replace with dim foreground color
-		if code[0] == '3' || code[0] == '9' {
-			return ansi.ColorCode(theme.Fog.Text)
-		}
-
-		// all background theme,
-		// we replace with dim background color
-		if code[0] == '4' || code[0] == '1' {
-			return ansi.ColorCode(theme.Fog.Background)
-		}
-	}
-
-	return ansi.Reset
-}
-
-func renderIdentifier(
-	lines []string,
-	pane *Pane,
-	theme *Theme,
-	identifier *Identifier,
-) {
-	moveCursor(pane.GetScreenXY(lines, identifier.X, identifier.Y))
-
-	fmt.Print(ansi.ColorFunc(theme.Identifier)(identifier.Value))
-}
-
-func renderCandidates(
-	lines []string,
-	pane *Pane,
-	theme *Theme,
-	candidates []*Candidate,
-) {
-	// first we need to draw existing candidates
-	for _, candidate := range candidates {
-		if !candidate.Selected {
-			renderCandidate(lines, pane, theme, candidate)
-		}
-	}
-
-	// and only then we draw selected one
-	// otherwise we can have incorrect color for selected candidate (it will
-	// partially look like 'normal' candidate)
-	for _, candidate := range candidates {
-		if candidate.Selected {
-			renderCandidate(lines, pane, theme, candidate)
-		}
-	}
-}
-
-func renderCandidate(
-	lines []string,
-	pane *Pane,
-	theme *Theme,
-	candidate *Candidate,
-) {
-	x := candidate.X
-	y := candidate.Y
-
-	moveCursor(pane.GetScreenXY(lines, x, y))
-
-	var color string
-	switch {
-	case candidate.Selected:
-		color = theme.Candidate.Selected
-
-	// case candidate.Parent != "":
-	//    color = theme.Candidate.Nested
-
-	default:
-		color = theme.Candidate.Normal
-	}
-
-	fmt.Print(ansi.ColorFunc(color)(candidate.Value))
-}
-
-func useCurrentCandidate(
-	tmux *Tmux,
-	pane *Pane,
-	identifier *Identifier,
-	candidates []*Candidate,
-	program string,
-	withPrefix bool,
-) {
-	selected := getSelectedCandidate(candidates)
-	if selected == nil {
-		return
-	}
-
-	var text string
-	if program != "" {
-		// if we want to run program then we don't need to remove existing
-		// identifier prefix
-		text = selected.Value
-	} else {
-		text = selected.Value
-		if withPrefix {
-			text = string([]rune(text)[identifier.Length():])
-		}
-	}
-
-	if program != "" {
-		_, _, err := executil.Run(exec.Command(program, text))
-		if err != nil {
-			log.Fatalln(err)
-		}
-	} else {
-		err := tmux.Paste(text, "-t", pane.ID)
-		if err != nil {
-			log.Fatalln(err)
-		}
-	}
-}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+)
+
+// menuKeys are used to navigate the menu, so actions can't be bound to them
+var menuKeys = []string{"j", "k", "Up", "Down", "Enter", "Escape", "C-c"}
+
+type Menu struct {
+	Actions  []Action
+	Selected int
+}
+
+// handle processes key event and returns chosen action, menu should be closed
+// if the second returned value is true.
+func (menu *Menu) handle(event termbox.Event) (*Action, bool) {
+	key := getKey(event)
+
+	for i, action := range menu.Actions {
+		if action.Key == "" {
+			continue
+		}
+
+		if shortcut, err := parseKey(action.Key); err == nil && shortcut == key {
+			return &menu.Actions[i], true
+		}
+	}
+
+	switch {
+	case event.Key == termbox.KeyEnter:
+		return &menu.Actions[menu.Selected], true
+
+	case event.Key == termbox.KeyEsc, event.Key == termbox.KeyCtrlC:
+		return nil, true
+
+	case event.Ch == 'j', event.Key == termbox.KeyArrowDown:
+		menu.Selected = (menu.Selected + 1) % len(menu.Actions)
+
+	case event.Ch == 'k', event.Key == termbox.KeyArrowUp:
+		menu.Selected = (menu.Selected - 1 + len(menu.Actions)) % len(menu.Actions)
+	}
+
+	return nil, false
+}
+
+func (menu *Menu) getItems() []string {
+	items := []string{}
+	width := 0
+
+	for _, action := range menu.Actions {
+		name := action.Name
+		if name == "" {
+			name = action.Type
+		}
+
+		item := fmt.Sprintf(" %-2s %s ", action.Key, name)
+		if len([]rune(item)) > width {
+			width = len([]rune(item))
+		}
+
+		items = append(items, item)
+	}
+
+	for i, item := range items {
+		items[i] = fmt.Sprintf("%-*s", width, item)
+	}
+
+	return items
+}
+
+// renderMenu draws menu right below selected candidate or above it if there
+// is no space left below.
+func renderMenu(
+	screen Screen,
+	lines []string,
+	pane *Pane,
+	theme *Theme,
+	menu *Menu,
+	candidate *Candidate,
+) {
+	items := menu.getItems()
+
+	x, y := pane.GetScreenXY(lines, candidate.X, candidate.Y)
+	if y+1+len(items) <= pane.Height {
+		y++
+	} else {
+		y -= len(items)
+	}
+
+	if width := getStringWidth(items[0]); x+width > pane.Width {
+		x = pane.Width - width
+	}
+
+	if x < 0 {
+		x = 0
+	}
+
+	if y < 0 {
+		y = 0
+	}
+
+	for i, item := range items {
+		style := parseStyle(theme.Menu.Normal)
+		if i == menu.Selected {
+			style = parseStyle(theme.Menu.Selected)
+		}
+
+		column := x
+		for _, symbol := range item {
+			screen.SetCell(column, y+i, symbol, style.Fg, style.Bg)
+
+			column += getRuneWidth(symbol)
+		}
+	}
+}
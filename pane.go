@@ -1,11 +1,11 @@
 package main
 
 import (
-	"regexp"
 	"strings"
-)
+	"unicode/utf8"
 
-var reEscapeSequence = regexp.MustCompile(`\x1b\[([^m]+)m`)
+	"github.com/mattn/go-runewidth"
+)
 
 type Pane struct {
 	ID    string   `json:"id,omitempty"`
@@ -21,99 +21,327 @@ func CapturePane(tmux *Tmux, id string, args ...string) (*Pane, error) {
 		return nil, err
 	}
 
-	width, height, err := tmux.GetPaneSize()
+	pane := &Pane{
+		ID:    id,
+		Lines: strings.Split(strings.TrimRight(contents, "\n"), "\n"),
+	}
+
+	err = pane.UpdateSize(tmux)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Pane{
-		ID:     id,
-		Lines:  strings.Split(strings.TrimRight(contents, "\n"), "\n"),
-		Width:  width,
-		Height: height,
-	}, nil
+	return pane, nil
+}
+
+// UpdateSize reads actual size of the pane, lines of the pane are captured
+// joined, so they are wrapped by the pane width only during rendering.
+func (pane *Pane) UpdateSize(tmux *Tmux) error {
+	width, height, err := tmux.GetPaneSize(pane.ID)
+	if err != nil {
+		return err
+	}
+
+	pane.Width = width
+	pane.Height = height
+
+	return nil
 }
 
+// GetBufferXY converts screen coordinates into line and offset in the line.
 func (pane *Pane) GetBufferXY(lines []string, x, y int) (int, int) {
-	for row, line := range lines {
-		offset := (len([]rune(line)) - 1) / pane.Width
+	if pane.Width <= 0 {
+		return x, y
+	}
 
-		if row+offset >= y {
-			x = x + (y-row)*pane.Width
-			y = row
-			break
+	// row is a screen row where line starts
+	row := 0
+	for index, line := range lines {
+		symbols := []rune(line)
+
+		rows := pane.getRows(symbols)
+		if y < row+rows {
+			return pane.getLineOffset(symbols, x, y-row), index
 		}
 
-		y -= offset
+		row += rows
 	}
 
-	return x, y
+	// every line below the last one takes single row
+	return x, y - row + len(lines)
 }
 
+// GetScreenXY converts line and offset in the line into screen coordinates.
 func (pane *Pane) GetScreenXY(lines []string, x, y int) (int, int) {
-	offset := 0
+	if pane.Width <= 0 {
+		return x, y
+	}
+
+	row := 0
+	for index, line := range lines {
+		symbols := []rune(line)
+
+		if index == y {
+			column, offset := pane.getLinePosition(symbols, x)
 
-	for row, line := range lines {
-		if row == y {
-			return x % pane.Width, y + x/pane.Width + offset
-		} else {
-			offset += (len([]rune(line)) - 1) / pane.Width
+			return column, row + offset
 		}
+
+		row += pane.getRows(symbols)
 	}
 
 	return x, y
 }
 
-func (pane *Pane) GetPrintable() []string {
-	printable := []string{}
+// getRows returns number of screen rows taken by line, empty line still
+// takes a row.
+func (pane *Pane) getRows(symbols []rune) int {
+	if len(symbols) == 0 {
+		return 1
+	}
+
+	_, row := pane.getLinePosition(symbols, len(symbols)-1)
+
+	return row + 1
+}
+
+// getLinePosition returns screen column and row relative to the start of line
+// for symbol with given offset, offsets after the end of line take single
+// column.
+func (pane *Pane) getLinePosition(symbols []rune, offset int) (int, int) {
+	column, row := 0, 0
+	for i, symbol := range symbols {
+		width := getRuneWidth(symbol)
+
+		column, row = pane.wrap(column, row, width)
+		if i == offset {
+			return column, row
+		}
+
+		column += width
+	}
+
+	column += offset - len(symbols)
+
+	return column % pane.Width, row + column/pane.Width
+}
+
+// getLineOffset returns offset of symbol in line which is drawn at given
+// screen column and row relative to the start of line.
+func (pane *Pane) getLineOffset(symbols []rune, x, y int) int {
+	column, row := 0, 0
+	for i, symbol := range symbols {
+		width := getRuneWidth(symbol)
+
+		column, row = pane.wrap(column, row, width)
+		if row > y || row == y && x < column+width {
+			return i
+		}
+
+		column += width
+	}
+
+	return len(symbols) + (y-row)*pane.Width + x - column
+}
+
+// wrap moves symbol of given width to the next row if it doesn't fit into
+// the rest of current row, tmux wraps wide symbols the same way.
+func (pane *Pane) wrap(column, row, width int) (int, int) {
+	if column > 0 && column+width > pane.Width {
+		return 0, row + 1
+	}
+
+	return column, row
+}
+
+// getRuneWidth returns number of screen columns taken by symbol, it's
+// calculated the same way as termbox does.
+func getRuneWidth(symbol rune) int {
+	width := runewidth.RuneWidth(symbol)
+	if width == 0 || width == 2 && runewidth.IsAmbiguousWidth(symbol) {
+		return 1
+	}
+
+	return width
+}
+
+// getStringWidth returns number of screen columns taken by text.
+func getStringWidth(text string) int {
+	width := 0
+	for _, symbol := range text {
+		width += getRuneWidth(symbol)
+	}
+
+	return width
+}
+
+// Cell is a printable symbol of pane with style it has been printed with.
+type Cell struct {
+	Ch rune
+	Style
+
+	// Link is a target of OSC 8 hyperlink the cell belongs to
+	Link string
+}
+
+// Hyperlink is a text printed as OSC 8 hyperlink, target of the link is not
+// visible on the screen.
+type Hyperlink struct {
+	X int
+	Y int
+
+	Text string
+	URI  string
+}
+
+// symbols of DEC special graphics charset that are used for drawing lines
+var gridSymbols = map[rune]rune{
+	'l': '┌',
+	'q': '─',
+	'w': '┬',
+	'k': '┐',
+	'x': '│',
+	't': '├',
+	'u': '┤',
+	'n': '┼',
+	'v': '┴',
+	'm': '└',
+	'j': '┘',
+}
+
+// GetCells returns printable symbols of every pane line with styles they've
+// been printed with.
+func (pane *Pane) GetCells() [][]Cell {
+	cells := [][]Cell{}
 
 	for _, line := range pane.Lines {
-		line = reEscapeSequence.ReplaceAllLiteralString(line, ``)
+		cells = append(cells, getLineCells(line))
+	}
 
-		inGrid := false
-		symbols := []rune(line)
-		for i := 0; i < len(symbols); i++ {
-			symbol := symbols[i]
-			if symbol == '\x0e' {
-				inGrid = true
-				symbols = append(symbols[:i], symbols[i+1:]...)
-				i--
+	return cells
+}
+
+func getLineCells(line string) []Cell {
+	var (
+		cells  = []Cell{}
+		style  = Style{}
+		link   = ""
+		inGrid = false
+	)
+
+	for i := 0; i < len(line); {
+		// OSC sequences are terminated by BEL or ST (ESC \)
+		if strings.HasPrefix(line[i:], "\x1b]") {
+			sequence, size := getOSCSequence(line[i+2:])
+
+			// OSC 8 ; params ; URI starts hyperlink, empty URI ends it
+			if strings.HasPrefix(sequence, "8;") {
+				if params := strings.SplitN(sequence, ";", 3); len(params) == 3 {
+					link = params[2]
+				}
+			}
+
+			i += 2 + size
+			continue
+		}
+
+		if strings.HasPrefix(line[i:], "\x1b[") {
+			end := strings.IndexByte(line[i+2:], 'm')
+			if end >= 0 {
+				style = applySGR(style, line[i+2:i+2+end])
+				i += 2 + end + 1
 				continue
 			}
+		}
+
+		symbol, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+
+		switch symbol {
+		case '\x0e':
+			inGrid = true
+			continue
+
+		case '\x0f':
+			inGrid = false
+			continue
+		}
+
+		if inGrid {
+			if grid, ok := gridSymbols[symbol]; ok {
+				symbol = grid
+			}
+		}
+
+		cells = append(cells, Cell{Ch: symbol, Style: style, Link: link})
+	}
 
-			if symbol == '\x0f' {
-				inGrid = false
-				symbols = append(symbols[:i], symbols[i+1:]...)
-				i--
+	return cells
+}
+
+// getLineText returns printable symbols of line without escape sequences.
+func getLineText(line string) string {
+	var text strings.Builder
+	for _, cell := range getLineCells(line) {
+		text.WriteRune(cell.Ch)
+	}
+
+	return text.String()
+}
+
+// getOSCSequence returns contents of OSC sequence and its length including
+// terminator, unterminated sequence takes the rest of the line.
+func getOSCSequence(text string) (string, int) {
+	end := len(text)
+	size := len(text)
+
+	if index := strings.IndexByte(text, '\a'); index >= 0 {
+		end, size = index, index+1
+	}
+
+	if index := strings.Index(text, "\x1b\\"); index >= 0 && index < end {
+		end, size = index, index+2
+	}
+
+	return text[:end], size
+}
+
+// GetHyperlinks returns all OSC 8 hyperlinks found in the pane.
+func (pane *Pane) GetHyperlinks() []Hyperlink {
+	hyperlinks := []Hyperlink{}
+
+	for y, line := range pane.GetCells() {
+		for x := 0; x < len(line); x++ {
+			if line[x].Link == "" {
 				continue
 			}
 
-			if inGrid {
-				switch symbol {
-				case 'l':
-					symbols[i] = '┌'
-				case 'q':
-					symbols[i] = '─'
-				case 'w':
-					symbols[i] = '┬'
-				case 'k':
-					symbols[i] = '┐'
-				case 'x':
-					symbols[i] = '│'
-				case 't':
-					symbols[i] = '├'
-				case 'u':
-					symbols[i] = '┤'
-				case 'n':
-					symbols[i] = '┼'
-				case 'v':
-					symbols[i] = '┴'
-				case 'm':
-					symbols[i] = '└'
-				case 'j':
-					symbols[i] = '┘'
-				}
+			hyperlink := Hyperlink{X: x, Y: y, URI: line[x].Link}
+
+			text := []rune{}
+			for ; x < len(line) && line[x].Link == hyperlink.URI; x++ {
+				text = append(text, line[x].Ch)
 			}
+
+			// step back, so next link right after this one is not skipped
+			x--
+
+			hyperlink.Text = string(text)
+
+			hyperlinks = append(hyperlinks, hyperlink)
+		}
+	}
+
+	return hyperlinks
+}
+
+func (pane *Pane) GetPrintable() []string {
+	printable := []string{}
+
+	for _, line := range pane.GetCells() {
+		symbols := make([]rune, len(line))
+		for i, cell := range line {
+			symbols[i] = cell.Ch
 		}
 
 		printable = append(printable, string(symbols))
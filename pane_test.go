@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaneCoordinatesRoundTrip(t *testing.T) {
+	test := assert.New(t)
+
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 1000; i++ {
+		pane := &Pane{Width: 1 + random.Intn(10)}
+
+		lines := make([]string, 1+random.Intn(6))
+		for row := range lines {
+			// empty and exact-width lines are the interesting cases
+			length := random.Intn(3*pane.Width + 1)
+			if random.Intn(3) == 0 {
+				length = pane.Width * random.Intn(3)
+			}
+
+			// wide symbols are wrapped when they don't fit into the row
+			symbols := make([]rune, length)
+			for i := range symbols {
+				symbols[i] = []rune("x漢")[random.Intn(2)]
+			}
+
+			lines[row] = string(symbols)
+		}
+
+		for y, line := range lines {
+			for x := 0; x < len([]rune(line)) || x == 0; x++ {
+				screenX, screenY := pane.GetScreenXY(lines, x, y)
+
+				if !test.True(
+					screenX >= 0 && screenX < pane.Width,
+					"width %d lines %q: %d:%d -> %d:%d",
+					pane.Width, lines, x, y, screenX, screenY,
+				) {
+					return
+				}
+
+				bufferX, bufferY := pane.GetBufferXY(lines, screenX, screenY)
+
+				if !test.Equal(
+					[]int{x, y}, []int{bufferX, bufferY},
+					"width %d lines %q: %d:%d -> %d:%d",
+					pane.Width, lines, x, y, screenX, screenY,
+				) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func FuzzGetPrintable(f *testing.F) {
+	f.Add("\x1b[31mred\x1b[0m plain")
+	f.Add("\x1b[38;5;1;48;2;1;2;3mcolors\x1b[m")
+	f.Add("\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x07")
+	f.Add("\x0eqqq\x0f\x1b[")
+	f.Add("\x1b]")
+
+	f.Fuzz(func(t *testing.T, contents string) {
+		pane := &Pane{Lines: strings.Split(contents, "\n"), Width: 8}
+
+		lines := pane.GetPrintable()
+		if len(lines) != len(pane.Lines) {
+			t.Fatalf("got %d printable lines for %d lines", len(lines), len(pane.Lines))
+		}
+
+		for y, line := range lines {
+			x, _ := pane.GetScreenXY(lines, len([]rune(line)), y)
+			if x < 0 || x >= pane.Width {
+				t.Fatalf("x is out of screen: %d", x)
+			}
+		}
+
+		pane.GetHyperlinks()
+	})
+}
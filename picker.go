@@ -0,0 +1,393 @@
+package main
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// escapeTime is time to wait for key following Escape, tmux sends Alt
+// combinations as Escape and the key at once.
+const escapeTime = 10 * time.Millisecond
+
+type Picker struct {
+	tmux   *Tmux
+	pane   *Pane
+	lines  []string
+	cells  [][]Cell
+	screen Screen
+	theme  *Theme
+	keymap Keymap
+	config *Config
+
+	identifier *Identifier
+	candidates []*Candidate
+
+	action     Action
+	actions    []Action
+	withPrefix bool
+
+	pattern    string
+	statusLine string
+
+	// plain is true when pane should be rendered as is, without fog
+	plain bool
+
+	search  *Search
+	matcher *regexp.Regexp
+
+	menu *Menu
+
+	// dryRun is true when action should not be performed, used action is
+	// stored instead
+	dryRun bool
+	used   *Action
+}
+
+func (picker *Picker) Run() error {
+	err := termbox.Init()
+	if err != nil {
+		return err
+	}
+
+	defer termbox.Close()
+
+	screen, err := newTerminalScreen()
+	if err != nil {
+		return err
+	}
+
+	defer screen.Close()
+
+	picker.screen = screen
+	picker.cells = picker.pane.GetCells()
+
+	events := pollEvents(termbox.PollEvent)
+
+	for {
+		err := picker.render()
+		if err != nil {
+			return err
+		}
+
+		done, err := picker.process(<-events)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// pollEvents returns terminal events, Escape immediately followed by another
+// key is reported as the key with Alt modifier, because termbox can report
+// either Escape or Alt combinations, but not both.
+func pollEvents(poll func() termbox.Event) <-chan termbox.Event {
+	polled := make(chan termbox.Event)
+	go func() {
+		for {
+			polled <- poll()
+		}
+	}()
+
+	events := make(chan termbox.Event)
+	go func() {
+		event := <-polled
+		for {
+			if !isEscape(event) {
+				events <- event
+				event = <-polled
+				continue
+			}
+
+			select {
+			case next := <-polled:
+				if next.Type == termbox.EventKey && !isEscape(next) {
+					next.Mod |= termbox.ModAlt
+					events <- next
+					event = <-polled
+				} else {
+					events <- event
+					event = next
+				}
+
+			case <-time.After(escapeTime):
+				events <- event
+				event = <-polled
+			}
+		}
+	}()
+
+	return events
+}
+
+func isEscape(event termbox.Event) bool {
+	return event.Type == termbox.EventKey && event.Key == termbox.KeyEsc &&
+		event.Ch == 0 && event.Mod == 0
+}
+
+// Replay runs picker without terminal, given events are processed as if they
+// have been received from terminal, screen is rendered into grid.
+func (picker *Picker) Replay(events []termbox.Event) error {
+	picker.screen = NewGrid(picker.pane.Width, picker.pane.Height)
+	picker.cells = picker.pane.GetCells()
+
+	for _, event := range events {
+		err := picker.render()
+		if err != nil {
+			return err
+		}
+
+		done, err := picker.process(event)
+		if err != nil || done {
+			return err
+		}
+
+		// grid is not resized by terminal, so it follows the pane
+		if event.Type == termbox.EventResize {
+			picker.screen = NewGrid(picker.pane.Width, picker.pane.Height)
+		}
+	}
+
+	return picker.render()
+}
+
+// process handles terminal event and returns true if picker should exit.
+func (picker *Picker) process(event termbox.Event) (bool, error) {
+	switch event.Type {
+	case termbox.EventKey:
+		if picker.search != nil && picker.search.Editing {
+			picker.edit(event)
+			return false, nil
+		}
+
+		if picker.menu != nil {
+			action, done := picker.menu.handle(event)
+			if done {
+				picker.menu = nil
+			}
+
+			if action != nil {
+				return true, picker.use(*action)
+			}
+
+			return false, nil
+		}
+
+		action, ok := picker.keymap[getKey(event)]
+		if !ok {
+			return false, nil
+		}
+
+		debug.Printf("key %v: %s", getKey(event), action)
+
+		return picker.handle(action)
+
+	case termbox.EventResize:
+		err := picker.pane.UpdateSize(picker.tmux)
+		if err != nil {
+			return false, err
+		}
+
+		debug.Printf(
+			"resized: screen %dx%d, pane %dx%d",
+			event.Width, event.Height,
+			picker.pane.Width, picker.pane.Height,
+		)
+
+	case termbox.EventError:
+		return false, event.Err
+	}
+
+	return false, nil
+}
+
+func (picker *Picker) render() error {
+	if picker.plain {
+		picker.screen.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	} else {
+		fog := parseStyle(picker.theme.Fog.Text)
+		picker.screen.Clear(fog.Fg, fog.Bg)
+	}
+
+	renderPane(picker.screen, picker.pane, picker.cells, picker.theme, !picker.plain)
+
+	if picker.matcher != nil {
+		renderSearchMatches(
+			picker.screen,
+			picker.lines,
+			picker.pane,
+			picker.theme,
+			getSearchMatches(picker.matcher, picker.lines),
+		)
+	}
+
+	renderIdentifier(
+		picker.screen,
+		picker.lines,
+		picker.pane,
+		picker.theme,
+		picker.identifier,
+	)
+
+	renderCandidates(
+		picker.screen,
+		picker.lines,
+		picker.pane,
+		picker.theme,
+		picker.candidates,
+	)
+
+	if picker.statusLine != statusLineNone {
+		status := getStatus(
+			picker.identifier,
+			picker.candidates,
+			picker.pattern,
+			picker.getPendingAction(),
+		)
+
+		debug.Printf("status: %s", status)
+
+		renderStatus(
+			picker.screen,
+			picker.pane,
+			picker.theme,
+			picker.statusLine,
+			status,
+		)
+	}
+
+	if picker.search != nil && picker.search.Editing {
+		renderSearchPrompt(
+			picker.screen,
+			picker.pane,
+			picker.theme,
+			picker.search,
+		)
+	}
+
+	if picker.menu != nil {
+		renderMenu(
+			picker.screen,
+			picker.lines,
+			picker.pane,
+			picker.theme,
+			picker.menu,
+			getSelectedCandidate(picker.candidates),
+		)
+	}
+
+	return picker.screen.Flush()
+}
+
+// edit passes key event to search prompt and updates matched candidates
+// according to typed query.
+func (picker *Picker) edit(event termbox.Event) {
+	editing := picker.search.edit(event)
+
+	picker.matcher = nil
+	if picker.search.Query != "" {
+		matcher, err := picker.search.Compile()
+		if err != nil {
+			// query can be incomplete regexp while it's being typed
+			debug.Printf("invalid search query: %s", err)
+		} else {
+			picker.matcher = matcher
+		}
+	}
+
+	markMatchedCandidates(picker.matcher, picker.lines, picker.candidates)
+
+	if !editing {
+		selectMatchedCandidate(picker.candidates, 1)
+	}
+}
+
+// getPendingAction returns what will be done with selected candidate on
+// accept.
+func (picker *Picker) getPendingAction() string {
+	if picker.menu != nil {
+		return picker.menu.Actions[picker.menu.Selected].Name
+	}
+
+	return picker.action.Name
+}
+
+func (picker *Picker) use(action Action) error {
+	if picker.dryRun {
+		picker.used = &action
+		return nil
+	}
+
+	return useCurrentCandidate(
+		picker.tmux,
+		picker.pane,
+		picker.identifier,
+		picker.candidates,
+		action,
+		picker.withPrefix,
+		picker.config,
+	)
+}
+
+// handle performs specified action and returns true if picker should exit.
+func (picker *Picker) handle(action string) (bool, error) {
+	switch action {
+	case actionSelectUp:
+		selectNextCandidate(picker.candidates, 0, -1)
+
+	case actionSelectDown:
+		selectNextCandidate(picker.candidates, 0, 1)
+
+	case actionSelectLeft:
+		selectNextCandidate(picker.candidates, -1, 0)
+
+	case actionSelectRight:
+		selectNextCandidate(picker.candidates, 1, 0)
+
+	case actionSelectNext:
+		selectCandidateInOrder(picker.candidates, 1)
+
+	case actionSelectPrev:
+		selectCandidateInOrder(picker.candidates, -1)
+
+	case actionSelectFirst:
+		selectFirstCandidate(picker.candidates)
+
+	case actionSelectLast:
+		selectLastCandidate(picker.candidates)
+
+	case actionSearch:
+		picker.search = &Search{Editing: true}
+		picker.matcher = nil
+
+		markMatchedCandidates(nil, picker.lines, picker.candidates)
+
+	case actionSearchNext:
+		selectMatchedCandidate(picker.candidates, 1)
+
+	case actionSearchPrev:
+		selectMatchedCandidate(picker.candidates, -1)
+
+	case actionToggleView:
+		picker.plain = !picker.plain
+
+	case actionMark:
+		if selected := getSelectedCandidate(picker.candidates); selected != nil {
+			selected.Marked = !selected.Marked
+		}
+
+	case actionCopy:
+		return true, picker.use(Action{Name: "copy", Type: actionTypeCopy})
+
+	case actionAccept:
+		return true, picker.use(picker.action)
+
+	case actionMenu:
+		picker.menu = &Menu{Actions: picker.actions}
+
+	case actionCancel:
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Screen is a surface to draw cells on, it's implemented by termbox itself
+// and by in-memory Grid.
+type Screen interface {
+	Clear(fg, bg termbox.Attribute)
+	SetCell(x, y int, symbol rune, fg, bg termbox.Attribute)
+	Flush() error
+}
+
+// terminalScreen draws cells on terminal itself, termbox is used only to set
+// up terminal and to read events, because termbox can't draw strikethrough and
+// draws default color with attributes as black in RGB mode.
+type terminalScreen struct {
+	out *os.File
+
+	width  int
+	height int
+
+	// back is drawn by SetCell and front is shown by terminal, only
+	// changed cells are drawn on flush
+	back  []termbox.Cell
+	front []termbox.Cell
+}
+
+func newTerminalScreen() (*terminalScreen, error) {
+	out, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &terminalScreen{out: out}, nil
+}
+
+func (screen *terminalScreen) Clear(fg, bg termbox.Attribute) {
+	// termbox updates its size only when its buffer is cleared
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+
+	width, height := termbox.Size()
+	if width != screen.width || height != screen.height {
+		screen.width, screen.height = width, height
+		screen.back = make([]termbox.Cell, width*height)
+		screen.front = nil
+	}
+
+	for i := range screen.back {
+		screen.back[i] = termbox.Cell{Ch: ' ', Fg: fg, Bg: bg}
+	}
+}
+
+func (screen *terminalScreen) SetCell(x, y int, symbol rune, fg, bg termbox.Attribute) {
+	if x < 0 || x >= screen.width || y < 0 || y >= screen.height {
+		return
+	}
+
+	screen.back[y*screen.width+x] = termbox.Cell{Ch: symbol, Fg: fg, Bg: bg}
+
+	if getRuneWidth(symbol) == 2 && x+1 < screen.width {
+		screen.back[y*screen.width+x+1] = termbox.Cell{Fg: fg, Bg: bg}
+	}
+}
+
+// Flush draws cells that have been changed since previous flush, everything
+// is drawn after terminal is resized.
+func (screen *terminalScreen) Flush() error {
+	if screen.front == nil {
+		screen.front = make([]termbox.Cell, len(screen.back))
+		for i := range screen.front {
+			screen.front[i].Ch = -1
+		}
+	}
+
+	var (
+		buffer = bytes.NewBuffer(nil)
+		style  = ""
+		lastX  = -1
+		lastY  = -1
+	)
+
+	for y := 0; y < screen.height; y++ {
+		for x := 0; x < screen.width; {
+			index := y*screen.width + x
+			cell := screen.back[index]
+
+			width := getRuneWidth(cell.Ch)
+			if cell == screen.front[index] {
+				x += width
+				continue
+			}
+
+			// second half of wide symbol should be drawn if it's replaced
+			if getRuneWidth(screen.front[index].Ch) == 2 && x+1 < screen.width {
+				screen.front[index+1].Ch = -1
+			}
+
+			screen.front[index] = cell
+
+			if x != lastX || y != lastY {
+				fmt.Fprintf(buffer, "\x1b[%d;%dH", y+1, x+1)
+			}
+
+			if sgr := getSGR(cell.Fg, cell.Bg); sgr != style {
+				buffer.WriteString(sgr)
+				style = sgr
+			}
+
+			symbol := cell.Ch
+			if symbol < ' ' || width == 2 && x == screen.width-1 {
+				symbol, width = ' ', 1
+			}
+
+			buffer.WriteRune(symbol)
+
+			x += width
+			lastX, lastY = x, y
+		}
+	}
+
+	buffer.WriteString("\x1b[0m")
+
+	_, err := screen.out.Write(buffer.Bytes())
+
+	return err
+}
+
+func (screen *terminalScreen) Close() error {
+	return screen.out.Close()
+}
+
+// getSGR returns SGR escape sequence which resets style and sets given
+// colors and attributes.
+func getSGR(fg, bg termbox.Attribute) string {
+	params := []string{"0"}
+
+	for _, attr := range []struct {
+		attr termbox.Attribute
+		code string
+	}{
+		{termbox.AttrBold, "1"},
+		{termbox.AttrDim, "2"},
+		{termbox.AttrCursive, "3"},
+		{termbox.AttrUnderline, "4"},
+		{termbox.AttrBlink, "5"},
+		{termbox.AttrReverse, "7"},
+		{termbox.AttrHidden, "8"},
+		{attrStrikethrough, "9"},
+	} {
+		if fg&attr.attr != 0 {
+			params = append(params, attr.code)
+		}
+	}
+
+	params = append(params, getSGRColor(getStyleColor(fg), false)...)
+	params = append(params, getSGRColor(getStyleColor(bg), true)...)
+
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// getSGRColor returns SGR parameters for termbox color, default color needs
+// no parameters because style is reset.
+func getSGRColor(color termbox.Attribute, background bool) []string {
+	base := 30
+	if background {
+		base = 40
+	}
+
+	if color == termbox.ColorDefault {
+		return nil
+	}
+
+	// RGB colors are stored above attributes
+	if color > termbox.AttrReverse {
+		r, g, b := termbox.AttributeToRGB(color)
+
+		return []string{
+			strconv.Itoa(base + 8), "2",
+			strconv.Itoa(int(r)), strconv.Itoa(int(g)), strconv.Itoa(int(b)),
+		}
+	}
+
+	index := int(color) - 1
+
+	switch {
+	case colorMode != colorMode16:
+		return []string{strconv.Itoa(base + 8), "5", strconv.Itoa(index)}
+	case index < 8:
+		return []string{strconv.Itoa(base + index)}
+	default:
+		return []string{strconv.Itoa(base + 60 + index - 8)}
+	}
+}
+
+// Grid is an in-memory screen.
+type Grid struct {
+	Width  int
+	Height int
+	Cells  []termbox.Cell
+}
+
+func NewGrid(width, height int) *Grid {
+	return &Grid{
+		Width:  width,
+		Height: height,
+		Cells:  make([]termbox.Cell, width*height),
+	}
+}
+
+func (grid *Grid) Clear(fg, bg termbox.Attribute) {
+	for i := range grid.Cells {
+		grid.Cells[i] = termbox.Cell{Ch: ' ', Fg: fg, Bg: bg}
+	}
+}
+
+func (grid *Grid) SetCell(x, y int, symbol rune, fg, bg termbox.Attribute) {
+	if x < 0 || x >= grid.Width || y < 0 || y >= grid.Height {
+		return
+	}
+
+	grid.Cells[y*grid.Width+x] = termbox.Cell{Ch: symbol, Fg: fg, Bg: bg}
+
+	// wide symbol takes next cell too, termbox skips it in the same way
+	if getRuneWidth(symbol) == 2 && x+1 < grid.Width {
+		grid.Cells[y*grid.Width+x+1] = termbox.Cell{Fg: fg, Bg: bg}
+	}
+}
+
+func (grid *Grid) Flush() error {
+	return nil
+}
+
+func (grid *Grid) Get(x, y int) termbox.Cell {
+	return grid.Cells[y*grid.Width+x]
+}
+
+func renderPane(
+	screen Screen,
+	pane *Pane,
+	cells [][]Cell,
+	theme *Theme,
+	fog bool,
+) {
+	// fog styles are parsed once, not for every cell
+	fogText := parseStyle(theme.Fog.Text)
+	fogBackground := parseStyle(theme.Fog.Background)
+
+	y := 0
+	for _, line := range cells {
+		column, row := 0, 0
+
+		for _, cell := range line {
+			style := cell.Style
+			if fog {
+				style = getFogStyle(style, fogText, fogBackground)
+			}
+
+			width := getRuneWidth(cell.Ch)
+
+			column, row = pane.wrap(column, row, width)
+
+			screen.SetCell(column, y+row, cell.Ch, style.Fg, style.Bg)
+
+			column += width
+		}
+
+		// wrapped line takes several rows on the screen
+		y += row + 1
+	}
+}
+
+// getFogStyle returns dimmed version of given style, so pane contents don't
+// distract from candidates. Colors are replaced by fog colors, but attributes
+// like bold or underline are kept, so pane keeps its structure.
+func getFogStyle(style Style, text Style, background Style) Style {
+	fog := text
+
+	// cells with background or reversed colors are replaced with dim
+	// background to keep them distinguishable
+	if getStyleColor(style.Bg) != termbox.ColorDefault ||
+		style.Fg&termbox.AttrReverse != 0 {
+		fog = background
+	}
+
+	// reverse is replaced by fog background and blinking text is distracting
+	attrs := getAttrs(style.Fg) &^ (termbox.AttrReverse | termbox.AttrBlink)
+
+	return Style{Fg: fog.Fg | attrs, Bg: fog.Bg}
+}
+
+func renderText(
+	screen Screen,
+	lines []string,
+	pane *Pane,
+	x int,
+	y int,
+	text string,
+	style Style,
+) {
+	for i, symbol := range []rune(text) {
+		screenX, screenY := pane.GetScreenXY(lines, x+i, y)
+
+		screen.SetCell(screenX, screenY, symbol, style.Fg, style.Bg)
+	}
+}
+
+func renderIdentifier(
+	screen Screen,
+	lines []string,
+	pane *Pane,
+	theme *Theme,
+	identifier *Identifier,
+) {
+	renderText(
+		screen, lines, pane,
+		identifier.X, identifier.Y, identifier.Value,
+		parseStyle(theme.Identifier),
+	)
+}
+
+func renderCandidates(
+	screen Screen,
+	lines []string,
+	pane *Pane,
+	theme *Theme,
+	candidates []*Candidate,
+) {
+	// candidates share few styles, so each style is parsed only once
+	styles := map[string]Style{}
+	getStyle := func(candidate *Candidate) Style {
+		name := getCandidateStyle(theme, candidate)
+
+		style, ok := styles[name]
+		if !ok {
+			style = parseStyle(name)
+			styles[name] = style
+		}
+
+		return style
+	}
+
+	// first we need to draw existing candidates, nested candidates are drawn
+	// over their parents, so trimmed part of parent is visible
+	for _, candidate := range candidates {
+		if !candidate.Selected && candidate.Parent == "" {
+			renderCandidate(screen, lines, pane, candidate, getStyle(candidate))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if !candidate.Selected && candidate.Parent != "" {
+			renderCandidate(screen, lines, pane, candidate, getStyle(candidate))
+		}
+	}
+
+	// and only then we draw selected one
+	// otherwise we can have incorrect color for selected candidate (it will
+	// partially look like 'normal' candidate)
+	for _, candidate := range candidates {
+		if candidate.Selected {
+			renderCandidate(screen, lines, pane, candidate, getStyle(candidate))
+		}
+	}
+}
+
+func renderCandidate(
+	screen Screen,
+	lines []string,
+	pane *Pane,
+	candidate *Candidate,
+	style Style,
+) {
+	renderText(
+		screen, lines, pane,
+		candidate.X, candidate.Y, candidate.Value,
+		style,
+	)
+}
+
+// getCandidateStyle returns style of candidate, state of candidate is more
+// important than its kind, normal style is used if there is no specific
+// style in theme.
+func getCandidateStyle(theme *Theme, candidate *Candidate) string {
+	styles := theme.Candidate
+
+	switch {
+	case candidate.Selected:
+		return styles.Selected
+
+	case candidate.Marked && styles.Marked != "":
+		return styles.Marked
+
+	case candidate.Matched && styles.Matched != "":
+		return styles.Matched
+
+	case candidate.Target != "" && styles.Link != "":
+		return styles.Link
+
+	case candidate.Parent != "" && styles.Nested != "":
+		return styles.Nested
+	}
+
+	var style string
+
+	switch candidate.Type() {
+	case candidateTypeURL:
+		style = styles.Type.URL
+	case candidateTypePath:
+		style = styles.Type.Path
+	case candidateTypeHash:
+		style = styles.Type.Hash
+	case candidateTypeNumber:
+		style = styles.Type.Number
+	case candidateTypeWord:
+		style = styles.Type.Word
+	}
+
+	if style != "" {
+		return style
+	}
+
+	return styles.Normal
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden snapshots")
+
+type snapshot struct {
+	name       string
+	lines      []string
+	width      int
+	height     int
+	keys       string
+	statusLine string
+
+	// pane is resized to given size after keys are replayed
+	resizeWidth  int
+	resizeHeight int
+}
+
+var snapshots = []snapshot{
+	{
+		name: "fog",
+		lines: []string{
+			"\x1b[31mred\x1b[0m foobar \x1b[44mblue\x1b[0m",
+			"\x1b[7mreverse\x1b[27m foobaz",
+			"\x1b[1;4:3mbold\x1b[22;24m \x1b[48;2;30;30;46mtruecolor\x1b[m",
+			"\x1b[3mitalic\x1b[23m \x1b[9mstrike\x1b[29m",
+			"$ foo",
+		},
+		width:  24,
+		height: 6,
+	},
+	{
+		name: "wrapped",
+		lines: []string{
+			"foo1 foo2 foo3 foo4 foo5 foo6",
+			"$ foo",
+		},
+		width:  12,
+		height: 5,
+		keys:   "k h",
+	},
+	{
+		name: "marked-plain",
+		lines: []string{
+			"foo1 foo2",
+			"$ foo",
+		},
+		width:  12,
+		height: 3,
+		keys:   "Space h v",
+	},
+	{
+		name: "status-menu",
+		lines: []string{
+			"foo1 foo2",
+			"foo3",
+			"$ foo",
+		},
+		width:      24,
+		height:     9,
+		keys:       "k a j",
+		statusLine: statusLineBottom,
+	},
+	{
+		name: "nested",
+		lines: []string{
+			`(foo1) "foo2":`,
+			"$ foo",
+		},
+		width:  16,
+		height: 3,
+	},
+	{
+		name: "hyperlink",
+		lines: []string{
+			"\x1b]8;;file:///tmp/foo.go\x1b\\foo.go\x1b]8;;\x1b\\ foo.c",
+			"$ foo",
+		},
+		width:  16,
+		height: 3,
+	},
+	{
+		name: "wide",
+		lines: []string{
+			"漢字 foo1 漢字 foo2",
+			"😀 $ foo",
+		},
+		width:  11,
+		height: 4,
+	},
+	{
+		name: "search",
+		lines: []string{
+			"foo1 bar foo2",
+			"$ foo",
+		},
+		width:  16,
+		height: 4,
+		keys:   "/ b a",
+	},
+	{
+		name: "resized",
+		lines: []string{
+			"foo1 foo2 foo3 foo4 foo5 foo6",
+			"$ foo",
+		},
+		width:        30,
+		height:       4,
+		keys:         "l",
+		resizeWidth:  12,
+		resizeHeight: 5,
+	},
+}
+
+func TestRenderCandidatesIntoGrid(t *testing.T) {
+	test := assert.New(t)
+
+	theme := &Theme{}
+	theme.Identifier = "default+u:default"
+	theme.Candidate.Normal = "green:default"
+	theme.Candidate.Selected = "16+b:green"
+	theme.Fog.Text = "236:default"
+	theme.Fog.Background = "238:236"
+
+	pane := &Pane{
+		Lines:  []string{"\x1b[31mfoo\x1b[0m \x1b[7mbar\x1b[27m", "$ f"},
+		Width:  5,
+		Height: 4,
+	}
+
+	var (
+		cells = pane.GetCells()
+		lines = pane.GetPrintable()
+		grid  = NewGrid(pane.Width, pane.Height)
+	)
+
+	test.Equal([]string{"foo bar", "$ f"}, lines)
+	test.Equal(getColor(1), cells[0][0].Fg)
+	test.Equal(termbox.AttrReverse, cells[0][4].Fg)
+
+	candidates := []*Candidate{
+		{Identifier: &Identifier{X: 0, Y: 0, Value: "foo"}, Selected: true},
+	}
+
+	renderPane(grid, pane, cells, theme, true)
+	renderIdentifier(grid, lines, pane, theme, &Identifier{X: 2, Y: 1, Value: "f"})
+	renderCandidates(grid, lines, pane, theme, candidates)
+
+	test.Equal(
+		termbox.Cell{Ch: 'f', Fg: getColor(16) | termbox.AttrBold, Bg: getColor(2)},
+		grid.Get(0, 0),
+	)
+
+	// fog replaces foreground colors with dim text
+	test.Equal(termbox.Cell{Ch: ' ', Fg: getColor(236)}, grid.Get(3, 0))
+
+	// wrapped reversed text gets dim background
+	test.Equal(termbox.Cell{Ch: 'r', Fg: getColor(238), Bg: getColor(236)}, grid.Get(1, 1))
+
+	// wrapped line shifts following lines down
+	test.Equal(
+		termbox.Cell{Ch: 'f', Fg: termbox.AttrUnderline},
+		grid.Get(2, 2),
+	)
+}
+
+// TestRenderSnapshots renders picker into grid and compares it with golden
+// snapshot, run go test -update to update snapshots after changing renderer
+// or themes.
+func TestRenderSnapshots(t *testing.T) {
+	test := assert.New(t)
+
+	theme, err := LoadTheme("share/themes", "light")
+	if err != nil {
+		panic(err)
+	}
+
+	keymap, err := NewKeymap(nil)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, snapshot := range snapshots {
+		pane := &Pane{
+			Lines:  snapshot.lines,
+			Width:  snapshot.width,
+			Height: snapshot.height,
+		}
+
+		lines := pane.GetPrintable()
+		y := len(lines) - 1
+
+		identifier, err := getIdentifierToComplete(
+			defaultRegexpCursor, lines, len([]rune(lines[y])), y,
+		)
+		test.NoError(err)
+
+		candidates, err := getCompletionCandidates(
+			defaultRegexpCandidate, lines, identifier,
+		)
+		test.NoError(err)
+
+		candidates = mergeHyperlinkCandidates(
+			candidates,
+			getHyperlinkCandidates(pane.GetHyperlinks(), identifier),
+		)
+
+		candidates = getUniqueCandidates(candidates)
+		selectDefaultCandidate(candidates, identifier.X, identifier.Y)
+
+		events, err := getKeyEvents(snapshot.keys)
+		test.NoError(err)
+
+		tmux := &Tmux{}
+		if snapshot.resizeWidth > 0 {
+			resized := &replayedPane{
+				Pane: &Pane{
+					ID:     pane.ID,
+					Width:  snapshot.resizeWidth,
+					Height: snapshot.resizeHeight,
+				},
+			}
+
+			tmux = &Tmux{backend: resized.exec}
+
+			events = append(events, termbox.Event{Type: termbox.EventResize})
+		}
+
+		picker := &Picker{
+			tmux:       tmux,
+			pane:       pane,
+			lines:      lines,
+			theme:      theme,
+			keymap:     keymap,
+			config:     &Config{},
+			identifier: identifier,
+			candidates: candidates,
+			action:     getDefaultAction("", false, false),
+			actions:    defaultActions,
+			pattern:    patternTypeDefault,
+			statusLine: snapshot.statusLine,
+			dryRun:     true,
+		}
+
+		if picker.statusLine == "" {
+			picker.statusLine = statusLineNone
+		}
+
+		test.NoError(picker.Replay(events))
+
+		actual := getSnapshot(picker.screen.(*Grid))
+		path := filepath.Join("unit_tests", "snapshots", snapshot.name)
+
+		if *update {
+			err := ioutil.WriteFile(path, []byte(actual), 0644)
+			if err != nil {
+				panic(err)
+			}
+
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(path)
+		if !test.NoError(err, "snapshot %s", snapshot.name) {
+			continue
+		}
+
+		test.Equal(string(expected), actual, "snapshot %s", snapshot.name)
+	}
+}
+
+// getSnapshot returns text representation of grid: symbols, then letters of
+// styles for every cell and then legend of styles.
+func getSnapshot(grid *Grid) string {
+	var (
+		symbols = []string{}
+		styles  = []string{}
+		legend  = []string{}
+		letters = map[string]byte{}
+	)
+
+	for y := 0; y < grid.Height; y++ {
+		symbolsRow, stylesRow := "", ""
+
+		for x := 0; x < grid.Width; x++ {
+			cell := grid.Get(x, y)
+
+			style := getSnapshotStyle(cell.Fg, cell.Bg)
+
+			letter, ok := letters[style]
+			if !ok {
+				letter = byte('a' + len(letters))
+				letters[style] = letter
+				legend = append(legend, string(letter)+" "+style)
+			}
+
+			// second cell of wide symbol is empty, so the row keeps its
+			// width in editors
+			if cell.Ch != 0 {
+				symbolsRow += string(cell.Ch)
+			}
+
+			stylesRow += string(letter)
+		}
+
+		// trailing spaces would be lost in editors
+		symbols = append(symbols, symbolsRow+"|")
+		styles = append(styles, stylesRow)
+	}
+
+	return strings.Join(symbols, "\n") + "\n\n" +
+		strings.Join(styles, "\n") + "\n\n" +
+		strings.Join(legend, "\n") + "\n"
+}
+
+// getSnapshotStyle formats style in theme format.
+func getSnapshotStyle(fg, bg termbox.Attribute) string {
+	attrs := ""
+	for _, attr := range []struct {
+		attr   termbox.Attribute
+		symbol string
+	}{
+		{termbox.AttrBold, "b"},
+		{termbox.AttrDim, "d"},
+		{termbox.AttrBlink, "B"},
+		{termbox.AttrUnderline, "u"},
+		{termbox.AttrCursive, "I"},
+		{termbox.AttrReverse, "i"},
+		{attrStrikethrough, "s"},
+	} {
+		if fg&attr.attr != 0 {
+			attrs += attr.symbol
+		}
+	}
+
+	style := getSnapshotColor(fg)
+	if attrs != "" {
+		style += "+" + attrs
+	}
+
+	style += ":" + getSnapshotColor(bg)
+	if bg&termbox.AttrBold != 0 {
+		style += "+b"
+	}
+
+	return style
+}
+
+func getSnapshotColor(attr termbox.Attribute) string {
+	color := attr & 0x1ff
+	if color == termbox.ColorDefault {
+		return "default"
+	}
+
+	return strconv.Itoa(int(color) - 1)
+}
+
+func TestGetCandidateStyle(t *testing.T) {
+	test := assert.New(t)
+
+	full := &Theme{}
+	full.Candidate.Normal = "normal"
+	full.Candidate.Selected = "selected"
+	full.Candidate.Marked = "marked"
+	full.Candidate.Matched = "matched"
+	full.Candidate.Link = "link"
+	full.Candidate.Nested = "nested"
+	full.Candidate.Type.URL = "url"
+	full.Candidate.Type.Path = "path"
+	full.Candidate.Type.Hash = "hash"
+	full.Candidate.Type.Number = "number"
+	full.Candidate.Type.Word = "word"
+
+	minimal := &Theme{}
+	minimal.Candidate.Normal = "normal"
+	minimal.Candidate.Selected = "selected"
+
+	candidate := func(value string, setup func(*Candidate)) *Candidate {
+		candidate := &Candidate{Identifier: &Identifier{Value: value}}
+		setup(candidate)
+
+		return candidate
+	}
+
+	testcases := []struct {
+		candidate *Candidate
+		full      string
+	}{
+		{
+			candidate(">foo", func(c *Candidate) {
+				c.Selected, c.Marked, c.Matched = true, true, true
+				c.Target, c.Parent = "https://foo", "foo)"
+			}),
+			"selected",
+		},
+		{
+			candidate("foo", func(c *Candidate) {
+				c.Marked, c.Matched, c.Target, c.Parent = true, true, "https://foo", "foo)"
+			}),
+			"marked",
+		},
+		{
+			candidate("foo", func(c *Candidate) {
+				c.Matched, c.Target, c.Parent = true, "https://foo", "foo)"
+			}),
+			"matched",
+		},
+		{
+			candidate("foo", func(c *Candidate) {
+				c.Target, c.Parent = "https://foo", "foo)"
+			}),
+			"link",
+		},
+		{candidate("foo", func(c *Candidate) { c.Parent = "foo)" }), "nested"},
+		{candidate("https://foo", func(*Candidate) {}), "url"},
+		{candidate("/tmp/foo", func(*Candidate) {}), "path"},
+		{candidate("deadbeef1", func(*Candidate) {}), "hash"},
+		{candidate("42", func(*Candidate) {}), "number"},
+		{candidate("foo", func(*Candidate) {}), "word"},
+	}
+
+	for _, testcase := range testcases {
+		value := testcase.candidate.Value
+
+		test.Equal(testcase.full, getCandidateStyle(full, testcase.candidate), value)
+
+		// missing styles fall back to normal style
+		expected := "normal"
+		if testcase.candidate.Selected {
+			expected = "selected"
+		}
+
+		test.Equal(expected, getCandidateStyle(minimal, testcase.candidate), value)
+	}
+}
+
+func TestGetSGR(t *testing.T) {
+	test := assert.New(t)
+
+	defer func(mode string) { colorMode = mode }(colorMode)
+
+	colorMode = colorModeTruecolor
+	test.Equal("\x1b[0;1;4m", getSGR(parseStyle("default+bu").Fg, termbox.ColorDefault))
+
+	style := parseStyle("#cdd6f4+Is:#1e1e2e")
+	test.Equal("\x1b[0;3;9;38;2;205;214;244;48;2;30;30;46m", getSGR(style.Fg, style.Bg))
+
+	colorMode = colorMode256
+	style = parseStyle("196+s:235")
+	test.Equal("\x1b[0;9;38;5;196;48;5;235m", getSGR(style.Fg, style.Bg))
+
+	colorMode = colorMode16
+	style = parseStyle("red+hb:blue")
+	test.Equal("\x1b[0;1;91;44m", getSGR(style.Fg, style.Bg))
+}
+
+func TestTerminalScreenFlush(t *testing.T) {
+	test := assert.New(t)
+
+	defer func(mode string) { colorMode = mode }(colorMode)
+
+	colorMode = colorMode256
+
+	out, err := os.CreateTemp(t.TempDir(), "screen")
+	test.NoError(err)
+
+	screen := &terminalScreen{
+		out:    out,
+		width:  4,
+		height: 2,
+		back:   make([]termbox.Cell, 8),
+	}
+
+	for i := range screen.back {
+		screen.back[i] = termbox.Cell{Ch: ' '}
+	}
+
+	screen.SetCell(0, 0, '漢', getColor(1), termbox.ColorDefault)
+	screen.SetCell(2, 0, 'x', getColor(1)|attrStrikethrough, termbox.ColorDefault)
+	test.NoError(screen.Flush())
+
+	// only changed cells are drawn
+	screen.SetCell(1, 1, 'y', termbox.ColorDefault, termbox.ColorDefault)
+	test.NoError(screen.Flush())
+
+	drawn, err := os.ReadFile(out.Name())
+	test.NoError(err)
+	test.Equal(
+		"\x1b[1;1H\x1b[0;38;5;1m漢\x1b[0;9;38;5;1mx\x1b[0m \x1b[2;1H    \x1b[0m"+
+			"\x1b[2;2H\x1b[0my\x1b[0m",
+		string(drawn),
+	)
+}
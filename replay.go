@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+	"github.com/nsf/termbox-go"
+	"github.com/reconquest/karma-go"
+)
+
+const replayPaneID = "%0"
+
+var reFormatVariable = regexp.MustCompile(`#\{([@a-z_-]+)\}`)
+
+// replayedPane is a pane loaded from bug report or pane dump, it's served
+// by fake tmux backend.
+type replayedPane struct {
+	CursorX int   `json:"cursor_x"`
+	CursorY int   `json:"cursor_y"`
+	Pane    *Pane `json:"pane"`
+
+	// Args are command line flags of the reported run
+	Args map[string]interface{} `json:"args"`
+}
+
+// replayedOptions are recorded options which change found candidates,
+// selection or rendering, so replay shows the same picker as reported run.
+var replayedOptions = []string{
+	"--regexp-cursor",
+	"--regexp-candidate",
+	"--no-prefix",
+	"--url",
+	"--exec",
+	"--exec-cwd",
+	"--exec-paste",
+	"--status-line",
+	"--theme",
+	"--colors",
+}
+
+var reUsageDefault = regexp.MustCompile(`(?i)\[default: .*\]`)
+
+// loadReplayFile reads pane contents and cursor position from specified file.
+// File can be a bug report or a pane dump made by capture-pane, cursor is
+// assumed to be at the end of dump.
+func loadReplayFile(path string) (*replayedPane, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to read replay file: %s", path,
+		)
+	}
+
+	replayed, err := parseReplayFile(string(contents))
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to parse replay file: %s", path,
+		)
+	}
+
+	debug.Printf(
+		"replaying pane %dx%d with %d lines, cursor at %d:%d",
+		replayed.Pane.Width, replayed.Pane.Height, len(replayed.Pane.Lines),
+		replayed.CursorX, replayed.CursorY,
+	)
+
+	return replayed, nil
+}
+
+// applyReplayedArgs applies options recorded in bug report, options specified
+// in command line take precedence. docopt fills in defaults, so command line
+// is parsed again without them to find out which options are specified.
+func applyReplayedArgs(
+	args map[string]interface{},
+	recorded map[string]interface{},
+	argv []string,
+) error {
+	if len(recorded) == 0 {
+		return nil
+	}
+
+	parser := &docopt.Parser{HelpHandler: docopt.NoHelpHandler}
+
+	specified, err := parser.ParseArgs(
+		reUsageDefault.ReplaceAllString(usage, ""),
+		argv,
+		"",
+	)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to parse command line",
+		)
+	}
+
+	for _, option := range replayedOptions {
+		value, ok := recorded[option]
+		if !ok || value == nil {
+			continue
+		}
+
+		if specified[option] != nil && specified[option] != false {
+			continue
+		}
+
+		debug.Printf("replay: using recorded %s: %v", option, value)
+
+		args[option] = value
+	}
+
+	return nil
+}
+
+func parseReplayFile(contents string) (*replayedPane, error) {
+	if strings.HasPrefix(contents, "bug report:") {
+		// report is written after panic message and stacktrace
+		start := strings.LastIndex(contents, "\n{\n")
+		if start < 0 {
+			return nil, fmt.Errorf("bug report doesn't contain pane")
+		}
+
+		var replayed replayedPane
+
+		err := json.Unmarshal([]byte(contents[start:]), &replayed)
+		if err != nil {
+			return nil, err
+		}
+
+		if replayed.Pane == nil || replayed.Pane.Width == 0 {
+			return nil, fmt.Errorf("bug report doesn't contain pane")
+		}
+
+		if replayed.Pane.ID == "" {
+			replayed.Pane.ID = replayPaneID
+		}
+
+		return &replayed, nil
+	}
+
+	pane := &Pane{
+		ID:    replayPaneID,
+		Lines: strings.Split(strings.TrimRight(contents, "\n"), "\n"),
+	}
+
+	// lines of dump are not wrapped, so pane is made wide enough
+	lines := pane.GetPrintable()
+	for _, line := range lines {
+		if width := getStringWidth(line); width > pane.Width {
+			pane.Width = width
+		}
+	}
+
+	if pane.Width == 0 {
+		pane.Width = 1
+	}
+
+	pane.Height = len(lines)
+
+	y := len(lines) - 1
+	x := getStringWidth(lines[y])
+
+	// cursor stays on the last line even if line takes whole width
+	if x == pane.Width {
+		x--
+	}
+
+	return &replayedPane{CursorX: x, CursorY: y, Pane: pane}, nil
+}
+
+// exec replies to tmux commands used by picker, commands that change state of
+// tmux are only logged.
+func (replayed *replayedPane) exec(args []string, stdin io.Reader) (string, error) {
+	switch args[0] {
+	case "capture-pane":
+		return strings.Join(replayed.Pane.Lines, "\n") + "\n", nil
+
+	case "display-message":
+		if len(args) > 1 && args[1] == "-p" {
+			return replayed.format(args[len(args)-1]) + "\n", nil
+		}
+	}
+
+	debug.Printf("replay: skipping tmux command: %q", args)
+
+	return "", nil
+}
+
+func (replayed *replayedPane) format(format string) string {
+	cwd, _ := os.Getwd()
+
+	values := map[string]string{
+		"pane_id":           replayed.Pane.ID,
+		"pane_width":        fmt.Sprint(replayed.Pane.Width),
+		"pane_height":       fmt.Sprint(replayed.Pane.Height),
+		"cursor_x":          fmt.Sprint(replayed.CursorX),
+		"cursor_y":          fmt.Sprint(replayed.CursorY),
+		"pane_current_path": cwd,
+		"client_tty":        os.DevNull,
+	}
+
+	return reFormatVariable.ReplaceAllStringFunc(
+		format,
+		func(variable string) string {
+			return values[reFormatVariable.FindStringSubmatch(variable)[1]]
+		},
+	)
+}
+
+// getKeyEvents returns key events for space separated key names.
+func getKeyEvents(keys string) ([]termbox.Event, error) {
+	events := []termbox.Event{}
+
+	for _, name := range strings.Fields(keys) {
+		key, err := parseKey(name)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, termbox.Event{
+			Type: termbox.EventKey,
+			Key:  key.Key,
+			Ch:   key.Ch,
+			Mod:  key.Mod,
+		})
+	}
+
+	return events, nil
+}
+
+// printReplayResult prints selected and marked candidates and action which
+// would be performed.
+func printReplayResult(candidates []*Candidate, action *Action) {
+	if selected := getSelectedCandidate(candidates); selected != nil {
+		fmt.Println("selected:", selected.Value)
+	}
+
+	for _, candidate := range getMarkedCandidates(candidates) {
+		fmt.Println("marked:", candidate.Value)
+	}
+
+	if action != nil {
+		fmt.Println("action:", action.Name)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docopt/docopt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayPaneDump(t *testing.T) {
+	test := assert.New(t)
+
+	replayed, err := parseReplayFile("foo_bar foobaz\n\x1b[31mfoo\x1b[0m\n$ fo\n")
+	test.NoError(err)
+	test.Equal(14, replayed.Pane.Width)
+	test.Equal(4, replayed.CursorX)
+	test.Equal(2, replayed.CursorY)
+
+	tmux := &Tmux{backend: replayed.exec}
+
+	pane, err := CapturePane(tmux, replayPaneID, "-eJ")
+	test.NoError(err)
+	test.Equal(replayed.Pane, pane)
+
+	theme, err := LoadTheme("share/themes", "light")
+	test.NoError(err)
+
+	keymap, err := NewKeymap(nil)
+	test.NoError(err)
+
+	lines := pane.GetPrintable()
+	identifier := &Identifier{X: 2, Y: 2, Value: "fo"}
+
+	candidates, err := getCompletionCandidates(defaultRegexpCandidate, lines, identifier)
+	test.NoError(err)
+
+	candidates = getUniqueCandidates(candidates)
+	selectDefaultCandidate(candidates, identifier.X, identifier.Y)
+
+	events, err := getKeyEvents("k l Enter")
+	test.NoError(err)
+
+	picker := &Picker{
+		tmux:       tmux,
+		pane:       pane,
+		lines:      lines,
+		theme:      theme,
+		keymap:     keymap,
+		config:     &Config{},
+		identifier: identifier,
+		candidates: candidates,
+		action:     getDefaultAction("", false, false),
+		dryRun:     true,
+	}
+
+	test.NoError(picker.Replay(events))
+	test.Equal("foobaz", getSelectedCandidate(candidates).Value)
+	test.Equal("paste", picker.used.Name)
+}
+
+func TestApplyReplayedArgs(t *testing.T) {
+	test := assert.New(t)
+
+	replayed, err := parseReplayFile(`bug report: panic
+
+{
+  "args": {
+    "--regexp-candidate": "[0-9]+",
+    "--no-prefix": true,
+    "--theme": "dark",
+    "--picker": "fzf",
+    "--exec": null
+  },
+  "cursor_x": 1,
+  "cursor_y": 0,
+  "pane": {"width": 10, "height": 1, "lines": ["$ 1"]}
+}
+`)
+	test.NoError(err)
+
+	argv := []string{"-r", "[a-z]+", "replay", "report.log"}
+
+	parser := &docopt.Parser{HelpHandler: docopt.NoHelpHandler}
+
+	args, err := parser.ParseArgs(usage, argv, "")
+	test.NoError(err)
+
+	test.NoError(applyReplayedArgs(args, replayed.Args, argv))
+	test.Equal("[a-z]+", args["--regexp-candidate"])
+	test.Equal(defaultRegexpCursor, args["--regexp-cursor"])
+	test.Equal(true, args["--no-prefix"])
+	test.Equal("dark", args["--theme"])
+	test.Equal(pickerBuiltin, args["--picker"])
+	test.Nil(args["--exec"])
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/nsf/termbox-go"
+)
+
+type Search struct {
+	Query string
+
+	// Regexp is true when query is a regular expression, otherwise query is
+	// matched literally
+	Regexp bool
+
+	// Editing is true while query is being typed
+	Editing bool
+}
+
+func (search *Search) Compile() (*regexp.Regexp, error) {
+	if search.Regexp {
+		return regexp.Compile(search.Query)
+	}
+
+	return regexp.Compile(regexp.QuoteMeta(search.Query))
+}
+
+func (search *Search) Prompt() string {
+	if search.Regexp {
+		return "re/" + search.Query
+	}
+
+	return "/" + search.Query
+}
+
+// edit handles key event while query is being typed and returns false when
+// editing is finished.
+func (search *Search) edit(event termbox.Event) bool {
+	switch {
+	case event.Key == termbox.KeyEnter:
+		search.Editing = false
+
+	case event.Key == termbox.KeyEsc, event.Key == termbox.KeyCtrlC:
+		search.Editing = false
+		search.Query = ""
+
+	case event.Key == termbox.KeyCtrlR:
+		search.Regexp = !search.Regexp
+
+	case event.Key == termbox.KeyBackspace, event.Key == termbox.KeyBackspace2:
+		if query := []rune(search.Query); len(query) > 0 {
+			search.Query = string(query[:len(query)-1])
+		}
+
+	case event.Key == termbox.KeySpace:
+		search.Query += " "
+
+	case event.Ch != 0:
+		search.Query += string(event.Ch)
+	}
+
+	return search.Editing
+}
+
+// getSearchMatches returns positions of matched text for every line, positions
+// are given in runes, not in bytes.
+func getSearchMatches(matcher *regexp.Regexp, lines []string) [][][]int {
+	matches := make([][][]int, len(lines))
+
+	for row, line := range lines {
+		for _, match := range matcher.FindAllStringIndex(line, -1) {
+			if match[0] == match[1] {
+				continue
+			}
+
+			matches[row] = append(matches[row], []int{
+				len([]rune(line[:match[0]])),
+				len([]rune(line[:match[1]])),
+			})
+		}
+	}
+
+	return matches
+}
+
+// markMatchedCandidates marks candidates which value or line they are located
+// on is matched by given search.
+func markMatchedCandidates(
+	matcher *regexp.Regexp,
+	lines []string,
+	candidates []*Candidate,
+) {
+	for _, candidate := range candidates {
+		candidate.Matched = matcher != nil &&
+			(matcher.MatchString(candidate.Value) ||
+				matcher.MatchString(lines[candidate.Y]))
+	}
+}
+
+// selectMatchedCandidate moves selection to the next matched candidate in
+// reading order, step specifies direction.
+func selectMatchedCandidate(candidates []*Candidate, step int) {
+	selected := getSelectedCandidate(candidates)
+	if selected == nil {
+		return
+	}
+
+	ordered := getCandidatesInReadingOrder(candidates)
+
+	current := 0
+	for index, candidate := range ordered {
+		if candidate == selected {
+			current = index
+		}
+	}
+
+	for i := 1; i <= len(ordered); i++ {
+		index := (current + i*step) % len(ordered)
+		if index < 0 {
+			index += len(ordered)
+		}
+
+		if ordered[index].Matched {
+			selected.Selected = false
+			ordered[index].Selected = true
+
+			return
+		}
+	}
+}
+
+func renderSearchMatches(
+	screen Screen,
+	lines []string,
+	pane *Pane,
+	theme *Theme,
+	matches [][][]int,
+) {
+	style := parseStyle(theme.Search)
+
+	for row, ranges := range matches {
+		for _, match := range ranges {
+			text := string([]rune(lines[row])[match[0]:match[1]])
+
+			renderText(screen, lines, pane, match[0], row, text, style)
+		}
+	}
+}
+
+func renderSearchPrompt(screen Screen, pane *Pane, theme *Theme, search *Search) {
+	style := parseStyle(theme.Status.Text)
+
+	y := pane.Height - 1
+
+	x := 0
+	for _, symbol := range search.Prompt() {
+		screen.SetCell(x, y, symbol, style.Fg, style.Bg)
+		x++
+	}
+
+	for ; x < pane.Width; x++ {
+		screen.SetCell(x, y, ' ', style.Fg, style.Bg)
+	}
+}
@@ -0,0 +1,34 @@
+# Completes words from the current tmux pane using Tab, add following lines to
+# ~/.bashrc:
+#
+#   TMUX_AUTOCOMPLETE_COMMANDS="ssh scp ping curl"
+#   source /usr/share/tmux-autocomplete/completion/tmux-autocomplete.bash
+#
+# Words are completed only for listed commands which have no completion of
+# their own, use `complete -o bashdefault -o default -F _tmux_autocomplete
+# <command>` to enable it for other commands. File names are completed if no
+# words match. Set TMUX_AUTOCOMPLETE_WINDOW=1 to complete words from all panes
+# of the window.
+
+_tmux_autocomplete() {
+    local word="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+
+    [[ -n "$TMUX" ]] || return 0
+
+    local args=()
+    if [[ -n "$TMUX_AUTOCOMPLETE_WINDOW" ]]; then
+        args=(--window)
+    fi
+
+    COMPREPLY=($(tmux-autocomplete complete "${args[@]}" -- "$word" 2>/dev/null))
+}
+
+for _tmux_autocomplete_command in $TMUX_AUTOCOMPLETE_COMMANDS; do
+    complete -p "$_tmux_autocomplete_command" &>/dev/null && continue
+
+    complete -o bashdefault -o default -F _tmux_autocomplete \
+        "$_tmux_autocomplete_command"
+done
+
+unset _tmux_autocomplete_command
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+)
+
+const (
+	statusLineNone   = "none"
+	statusLineTop    = "top"
+	statusLineBottom = "bottom"
+)
+
+const (
+	patternTypeDefault = "default"
+	patternTypeRegexp  = "regexp"
+)
+
+func getPatternType(regexpCursor, regexpCandidate string) string {
+	if regexpCursor == defaultRegexpCursor &&
+		regexpCandidate == defaultRegexpCandidate {
+		return patternTypeDefault
+	}
+
+	return patternTypeRegexp
+}
+
+type Status struct {
+	Prefix  string
+	Index   int
+	Total   int
+	Value   string
+	Parent  string
+	Pattern string
+	Action  string
+}
+
+// Fields returns status line parts, value of selected candidate is returned
+// separately so it can be highlighted.
+func (status Status) Fields() (string, string, string) {
+	head := fmt.Sprintf(
+		" %d/%d  prefix: %s  value: ",
+		status.Index, status.Total, status.Prefix,
+	)
+
+	tail := ""
+	if status.Parent != "" {
+		tail += "  parent: " + status.Parent
+	}
+
+	tail += "  pattern: " + status.Pattern + "  action: " + status.Action
+
+	return head, status.Value, tail
+}
+
+func (status Status) String() string {
+	head, value, tail := status.Fields()
+
+	return head + value + tail
+}
+
+func getStatus(
+	identifier *Identifier,
+	candidates []*Candidate,
+	pattern string,
+	action string,
+) Status {
+	status := Status{
+		Total:   len(candidates),
+		Pattern: pattern,
+		Action:  action,
+	}
+
+	if identifier != nil {
+		status.Prefix = identifier.Value
+	}
+
+	for index, candidate := range getCandidatesInReadingOrder(candidates) {
+		if candidate.Selected {
+			status.Index = index + 1
+			status.Value = candidate.Value
+			status.Parent = candidate.Parent
+		}
+	}
+
+	return status
+}
+
+func renderStatus(
+	screen Screen,
+	pane *Pane,
+	theme *Theme,
+	position string,
+	status Status,
+) {
+	var y int
+	switch position {
+	case statusLineTop:
+		y = 0
+	case statusLineBottom:
+		y = pane.Height - 1
+	default:
+		return
+	}
+
+	head, value, tail := status.Fields()
+
+	var (
+		textStyle  = parseStyle(theme.Status.Text)
+		valueStyle = parseStyle(theme.Status.Value)
+	)
+
+	x := 0
+	for _, part := range []struct {
+		text  string
+		style Style
+	}{
+		{head, textStyle},
+		{value, valueStyle},
+		{tail, textStyle},
+	} {
+		for _, symbol := range part.text {
+			screen.SetCell(x, y, symbol, part.style.Fg, part.style.Bg)
+			x++
+		}
+	}
+
+	for ; x < pane.Width; x++ {
+		screen.SetCell(x, y, ' ', textStyle.Fg, textStyle.Bg)
+	}
+}
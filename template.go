@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholders are available in command templates as {name} and in
+// environment as TMUX_AUTOCOMPLETE_NAME
+var placeholders = []string{
+	"value",
+	"prefix",
+	"pane_id",
+	"pane_current_path",
+	"line",
+	"x",
+	"y",
+	"type",
+	"target",
+}
+
+// rePlaceholder matches only documented placeholders, other words in braces
+// are left as is
+var rePlaceholder = regexp.MustCompile(
+	`\{(` + strings.Join(placeholders, "|") + `)\}`,
+)
+
+// splitCommand splits command into arguments like shell does, but without
+// any expansions, single and double quotes and backslash escaping are
+// supported.
+func splitCommand(command string) ([]string, error) {
+	var (
+		args    = []string{}
+		arg     = strings.Builder{}
+		inArg   = false
+		quote   = rune(0)
+		escaped = false
+	)
+
+	for _, symbol := range command {
+		switch {
+		case escaped:
+			arg.WriteRune(symbol)
+			escaped = false
+
+		case symbol == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+
+		case quote != 0:
+			if symbol == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(symbol)
+			}
+
+		case symbol == '\'' || symbol == '"':
+			quote = symbol
+			inArg = true
+
+		case symbol == ' ' || symbol == '\t' || symbol == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+
+		default:
+			arg.WriteRune(symbol)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command: %s", command)
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("unterminated escape in command: %s", command)
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	return args, nil
+}
+
+// expandCommand splits command template into arguments and replaces
+// placeholders in every argument with given values, values never become
+// separate arguments, so there is no need to escape them. If template has no
+// placeholders then it's a program, which is run with value as the only
+// argument, so paths with spaces don't need quoting.
+func expandCommand(template string, values map[string]string) ([]string, error) {
+	if !rePlaceholder.MatchString(template) {
+		return []string{template, values["value"]}, nil
+	}
+
+	args, err := splitCommand(template)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, arg := range args {
+		args[i] = rePlaceholder.ReplaceAllStringFunc(
+			arg,
+			func(placeholder string) string {
+				return values[placeholder[1:len(placeholder)-1]]
+			},
+		)
+	}
+
+	return args, nil
+}
+
+// getTemplateEnv returns values as TMUX_AUTOCOMPLETE_* environment variables.
+func getTemplateEnv(values map[string]string) []string {
+	env := []string{}
+	for name, value := range values {
+		env = append(
+			env,
+			"TMUX_AUTOCOMPLETE_"+strings.ToUpper(name)+"="+value,
+		)
+	}
+
+	sort.Strings(env)
+
+	return env
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandCommand(t *testing.T) {
+	test := assert.New(t)
+
+	values := map[string]string{
+		"value": "foo; rm -rf ~",
+		"line":  "$ echo 'foo; rm -rf ~'",
+		"x":     "8",
+	}
+
+	args, err := expandCommand(`grep -n "{value}" --context='{x}'`, values)
+	test.NoError(err)
+	test.Equal([]string{"grep", "-n", "foo; rm -rf ~", "--context=8"}, args)
+
+	args, err = expandCommand(`notify-send {line}`, values)
+	test.NoError(err)
+	test.Equal([]string{"notify-send", "$ echo 'foo; rm -rf ~'"}, args)
+
+	args, err = expandCommand(`xdg-open`, values)
+	test.NoError(err)
+	test.Equal([]string{"xdg-open", "foo; rm -rf ~"}, args)
+
+	args, err = expandCommand(`/opt/My Tools/open`, values)
+	test.NoError(err)
+	test.Equal([]string{"/opt/My Tools/open", "foo; rm -rf ~"}, args)
+
+	args, err = expandCommand(`echo {x1} {unknown}`, values)
+	test.NoError(err)
+	test.Equal([]string{"echo {x1} {unknown}", "foo; rm -rf ~"}, args)
+
+	args, err = expandCommand(`echo {x1} {unknown} {x} a\ b`, values)
+	test.NoError(err)
+	test.Equal([]string{"echo", "{x1}", "{unknown}", "8", "a b"}, args)
+
+	_, err = expandCommand(`echo "{value}`, values)
+	test.Error(err)
+}
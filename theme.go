@@ -1,13 +1,15 @@
 package main
 
 import (
+	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 
-	"github.com/kovetskiy/ko"
 	"github.com/reconquest/karma-go"
 	yaml "gopkg.in/coryb/yaml.v2"
 )
@@ -15,16 +17,48 @@ import (
 type Theme struct {
 	Identifier string `required:"true"`
 
+	// Candidate styles except normal and selected are optional, normal style
+	// is used if style is not specified
 	Candidate struct {
 		Normal   string `required:"true"`
 		Selected string `required:"true"`
-		// Nested   string `required:"true"`
+		Marked   string
+
+		// Matched is used for candidates matched by search
+		Matched string
+
+		// Link is used for OSC 8 hyperlinks
+		Link string
+
+		// Nested is used for trimmed candidates, e.g. foo for foo),
+		Nested string
+
+		// Type is used for candidates of specific type
+		Type struct {
+			URL    string
+			Path   string
+			Hash   string
+			Number string
+			Word   string
+		}
 	} `required:"true"`
 
 	Fog struct {
 		Text       string `required:"true"`
 		Background string `required:"true"`
 	}
+
+	Search string `default:"default+b:yellow"`
+
+	Menu struct {
+		Normal   string `default:"default:252"`
+		Selected string `default:"default+b:250"`
+	}
+
+	Status struct {
+		Text  string `default:"default+i"`
+		Value string `default:"default+bi"`
+	}
 }
 
 var (
@@ -32,34 +66,228 @@ var (
 	defaultThemePath     = defaultSystemThemePath + `:` + defaultUserThemePath
 )
 
-func LoadTheme(dirs string, name string) (*Theme, error) {
-	var theme Theme
+// embeddedThemes are default themes built into binary, so they can be used
+// even if themes are not installed.
+//
+//go:embed share/themes/*.theme
+var embeddedThemes embed.FS
+
+const embeddedThemesDir = "(embedded)"
+
+// maxThemeExtends limits chain of extended themes, so themes extending each
+// other don't cause infinite loop.
+const maxThemeExtends = 10
+
+// themeSource is a directory with themes.
+type themeSource struct {
+	dir string
+	fs  fs.FS
+}
+
+// getThemeSources returns directories specified by --theme-path followed by
+// embedded themes.
+func getThemeSources(dirs string) ([]themeSource, error) {
+	sources := []themeSource{}
+
 	for _, dir := range strings.Split(dirs, ":") {
 		if dir == "" {
 			return nil, fmt.Errorf("empty directory with themes specified")
 		}
 
-		if strings.HasPrefix(dir, "~/") {
-			// 1 because need to trim only ~ symbol, slash is required
-			dir = os.Getenv("HOME") + dir[1:]
-		}
+		sources = append(sources, themeSource{
+			dir: dir,
+			fs:  os.DirFS(expandHome(dir)),
+		})
+	}
+
+	embedded, err := fs.Sub(embeddedThemes, "share/themes")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(sources, themeSource{dir: embeddedThemesDir, fs: embedded}), nil
+}
+
+func LoadTheme(dirs string, name string) (*Theme, error) {
+	sources, err := getThemeSources(dirs)
+	if err != nil {
+		return nil, err
+	}
 
-		path := filepath.Join(dir, name+".theme")
+	var theme Theme
+
+	err = loadTheme(sources, 0, name, &theme, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	err = theme.setDefaults()
+	if err != nil {
+		return nil, err
+	}
 
-		err := ko.Load(path, &theme, yaml.Unmarshal)
+	return &theme, nil
+}
+
+// loadTheme reads theme with given name from the first source starting from
+// specified one and decodes it into theme.
+func loadTheme(
+	sources []themeSource,
+	from int,
+	name string,
+	theme *Theme,
+	depth int,
+) error {
+	for index := from; index < len(sources); index++ {
+		source := sources[index]
+
+		data, err := fs.ReadFile(source.fs, name+".theme")
 		if err != nil {
-			if os.IsNotExist(err) {
+			if errors.Is(err, fs.ErrNotExist) {
 				continue
 			}
 
-			return nil, karma.Format(
+			return karma.Format(
+				err,
+				"unable to read theme file: %s",
+				filepath.Join(source.dir, name+".theme"),
+			)
+		}
+
+		err = decodeTheme(sources, index, name, data, theme, depth)
+		if err != nil {
+			return karma.Format(
 				err,
-				"unable to read theme file: %s", path,
+				"unable to read theme file: %s",
+				filepath.Join(source.dir, name+".theme"),
 			)
 		}
 
-		return &theme, nil
+		return nil
+	}
+
+	return errors.New("no such theme found")
+}
+
+// decodeTheme decodes theme data over the theme it extends. Theme can extend
+// theme with the same name, in that case it's searched in the following
+// sources, so user theme can change a few styles of system one.
+func decodeTheme(
+	sources []themeSource,
+	index int,
+	name string,
+	data []byte,
+	theme *Theme,
+	depth int,
+) error {
+	var header struct {
+		Extends string
+	}
+
+	err := yaml.Unmarshal(data, &header)
+	if err != nil {
+		return err
+	}
+
+	if header.Extends != "" {
+		if depth >= maxThemeExtends {
+			return fmt.Errorf(
+				"too many extended themes, probably themes extend each other",
+			)
+		}
+
+		from := 0
+		if header.Extends == name {
+			from = index + 1
+		}
+
+		err := loadTheme(sources, from, header.Extends, theme, depth+1)
+		if err != nil {
+			return karma.Format(
+				err,
+				"unable to load extended theme: %s", header.Extends,
+			)
+		}
+	}
+
+	return yaml.Unmarshal(data, theme)
+}
+
+// walkTheme calls given function for every style of theme, key is a path to
+// the style, e.g. candidate.normal.
+func walkTheme(
+	theme *Theme,
+	fn func(key string, field reflect.StructField, value reflect.Value),
+) {
+	var walk func(prefix string, value reflect.Value)
+	walk = func(prefix string, value reflect.Value) {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			key := prefix + strings.ToLower(field.Name)
+
+			switch value.Field(i).Kind() {
+			case reflect.String:
+				fn(key, field, value.Field(i))
+			case reflect.Struct:
+				walk(key+".", value.Field(i))
+			}
+		}
+	}
+
+	walk("", reflect.ValueOf(theme).Elem())
+}
+
+// setDefaults sets default values of missing styles, error is returned if
+// required style is missing.
+func (theme *Theme) setDefaults() error {
+	var missing []string
+
+	walkTheme(
+		theme,
+		func(key string, field reflect.StructField, value reflect.Value) {
+			if value.String() != "" {
+				return
+			}
+
+			if field.Tag.Get("required") == "true" {
+				missing = append(missing, key)
+				return
+			}
+
+			value.SetString(field.Tag.Get("default"))
+		},
+	)
+
+	if len(missing) > 0 {
+		return fmt.Errorf(
+			"missing required keys: %s", strings.Join(missing, ", "),
+		)
+	}
+
+	return nil
+}
+
+// getStyles returns styles of theme by their keys, e.g. candidate.normal.
+func (theme *Theme) getStyles() map[string]string {
+	styles := map[string]string{}
+
+	walkTheme(
+		theme,
+		func(key string, field reflect.StructField, value reflect.Value) {
+			styles[key] = value.String()
+		},
+	)
+
+	return styles
+}
+
+// hasRGBColors returns true if theme uses colors like #1e1e2e.
+func (theme *Theme) hasRGBColors() bool {
+	for _, style := range theme.getStyles() {
+		if strings.Contains(style, "#") {
+			return true
+		}
 	}
 
-	return nil, errors.New("no such theme found")
+	return false
 }
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	themeAuto = "auto"
+
+	themeSignalOption      = "option"
+	themeSignalWindowStyle = "window-style"
+	themeSignalOSC11       = "osc11"
+	themeSignalColorFgBg   = "colorfgbg"
+
+	// themeOption is a tmux user option which specifies theme or background
+	themeOption = "@autocomplete-theme"
+
+	defaultThemeQueryTimeout = 200
+)
+
+var defaultThemeSignals = []string{
+	themeSignalOption,
+	themeSignalWindowStyle,
+	themeSignalOSC11,
+	themeSignalColorFgBg,
+}
+
+var (
+	// reply to OSC 11 is \x1b]11;rgb:rrrr/gggg/bbbb terminated by BEL or ST
+	reOSC11Reply = regexp.MustCompile(
+		`\x1b\]11;rgb:([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})`,
+	)
+
+	// reply to primary device attributes request, every terminal replies to
+	// it, so there is no need to wait for timeout if OSC 11 is not supported
+	reDA1Reply = regexp.MustCompile(`\x1b\[\?[0-9;]*c`)
+
+	reStyleBackground = regexp.MustCompile(`(?:^|,)\s*bg=([^,\s]+)`)
+)
+
+// ThemeConfig configures --theme auto.
+type ThemeConfig struct {
+	// Auto is a list of signals which are checked in specified order until
+	// background is found: option, window-style, osc11 and colorfgbg, all
+	// signals are used if not specified
+	Auto []string
+
+	// Light and Dark are themes used for light and dark backgrounds,
+	// light and dark themes are used if not specified
+	Light string
+	Dark  string
+
+	// Timeout is a time in milliseconds to wait for terminal to reply to
+	// OSC 11 query, 200 if not specified
+	Timeout int
+}
+
+// getAutoTheme chooses theme according to background of terminal using
+// signals specified in config, light theme is used if background is unknown.
+func getAutoTheme(tmux *Tmux, config ThemeConfig) (string, error) {
+	signals := config.Auto
+	if len(signals) == 0 {
+		signals = defaultThemeSignals
+	}
+
+	themes := map[bool]string{false: config.Light, true: config.Dark}
+	if themes[false] == "" {
+		themes[false] = "light"
+	}
+
+	if themes[true] == "" {
+		themes[true] = "dark"
+	}
+
+	for _, signal := range signals {
+		var (
+			name string
+			dark bool
+			ok   bool
+			err  error
+		)
+
+		switch signal {
+		case themeSignalOption:
+			name, err = getThemeFromOption(tmux)
+			if name == "light" || name == "dark" {
+				name, dark, ok = "", name == "dark", true
+			}
+
+		case themeSignalWindowStyle:
+			dark, ok, err = isWindowStyleDark(tmux)
+
+		case themeSignalOSC11:
+			timeout := config.Timeout
+			if timeout == 0 {
+				timeout = defaultThemeQueryTimeout
+			}
+
+			dark, ok, err = isTerminalDark(time.Duration(timeout) * time.Millisecond)
+
+		case themeSignalColorFgBg:
+			dark, ok = isColorFgBgDark(os.Getenv("COLORFGBG"))
+
+		default:
+			return "", fmt.Errorf("unexpected theme signal: %q", signal)
+		}
+
+		if err != nil {
+			debug.Printf("theme: %s: %s", signal, err)
+			continue
+		}
+
+		if name != "" {
+			debug.Printf("theme: %s: using theme %q", signal, name)
+			return name, nil
+		}
+
+		if ok {
+			debug.Printf(
+				"theme: %s: background is %s, using theme %q",
+				signal, getBackgroundName(dark), themes[dark],
+			)
+
+			return themes[dark], nil
+		}
+
+		debug.Printf("theme: %s: background is unknown", signal)
+	}
+
+	debug.Printf("theme: background is unknown, using theme %q", themes[false])
+
+	return themes[false], nil
+}
+
+// getThemeFromOption returns value of @autocomplete-theme, it can be theme
+// name or background: light or dark.
+func getThemeFromOption(tmux *Tmux) (string, error) {
+	var value string
+
+	err := tmux.Eval(map[string]interface{}{themeOption: &value})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(value), nil
+}
+
+// isWindowStyleDark checks background of window-style option of tmux.
+func isWindowStyleDark(tmux *Tmux) (bool, bool, error) {
+	var style string
+
+	err := tmux.Eval(map[string]interface{}{"window-style": &style})
+	if err != nil {
+		return false, false, err
+	}
+
+	debug.Printf("theme: window-style is %q", style)
+
+	matches := reStyleBackground.FindStringSubmatch(style)
+	if matches == nil {
+		return false, false, nil
+	}
+
+	r, g, b, ok := parseTmuxColor(matches[1])
+	if !ok {
+		return false, false, nil
+	}
+
+	return isDark(r, g, b), true, nil
+}
+
+// parseTmuxColor parses colors used in tmux styles: #rrggbb, colour236,
+// red or brightred, default and terminal colors are unknown.
+func parseTmuxColor(value string) (int, int, int, bool) {
+	value = strings.ToLower(value)
+
+	if r, g, b, ok := parseHexColor(value); ok {
+		return r, g, b, true
+	}
+
+	for _, prefix := range []string{"colour", "color"} {
+		if strings.HasPrefix(value, prefix) {
+			index, err := strconv.Atoi(strings.TrimPrefix(value, prefix))
+			if err != nil || index < 0 || index > 255 {
+				return 0, 0, 0, false
+			}
+
+			r, g, b := getPaletteRGB(index)
+			return r, g, b, true
+		}
+	}
+
+	bright := strings.HasPrefix(value, "bright")
+
+	index, ok := colorNames[strings.TrimPrefix(value, "bright")]
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	if bright {
+		index += 8
+	}
+
+	r, g, b := getPaletteRGB(index)
+
+	return r, g, b, true
+}
+
+// isTerminalDark asks terminal about background color using OSC 11 escape
+// sequence. Query is sent to the pane, tmux replies with background of pane
+// or of the client terminal.
+func isTerminalDark(timeout time.Duration) (bool, bool, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, false, err
+	}
+
+	defer tty.Close()
+
+	state, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return false, false, err
+	}
+
+	defer term.Restore(int(tty.Fd()), state)
+
+	err = tty.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return false, false, err
+	}
+
+	_, err = tty.WriteString("\x1b]11;?\x1b\\\x1b[c")
+	if err != nil {
+		return false, false, err
+	}
+
+	reply := []byte{}
+	buffer := make([]byte, 64)
+
+	for {
+		size, err := tty.Read(buffer)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return false, false, fmt.Errorf(
+					"no reply from terminal in %s", timeout,
+				)
+			}
+
+			return false, false, err
+		}
+
+		reply = append(reply, buffer[:size]...)
+
+		// terminal replies to queries in order, so reply to device
+		// attributes means that all replies have been received
+		if !reDA1Reply.Match(reply) {
+			continue
+		}
+
+		debug.Printf("theme: terminal replied %q", reply)
+
+		matches := reOSC11Reply.FindSubmatch(reply)
+		if matches == nil {
+			return false, false, nil
+		}
+
+		rgb := []int{}
+		for _, component := range matches[1:] {
+			value, _ := strconv.ParseUint(string(component), 16, 16)
+
+			// components have 1-4 hex digits, they are scaled to 0-255
+			max := uint64(1)<<(4*len(component)) - 1
+			rgb = append(rgb, int(value*255/max))
+		}
+
+		return isDark(rgb[0], rgb[1], rgb[2]), true, nil
+	}
+}
+
+// isColorFgBgDark checks $COLORFGBG which is set by some terminals, e.g.
+// rxvt and konsole, format is fg;bg or fg;default;bg.
+func isColorFgBgDark(value string) (bool, bool) {
+	if value == "" {
+		return false, false
+	}
+
+	debug.Printf("theme: $COLORFGBG is %q", value)
+
+	fields := strings.Split(value, ";")
+
+	index, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || index < 0 || index > 255 {
+		return false, false
+	}
+
+	r, g, b := getPaletteRGB(index)
+
+	return isDark(r, g, b), true
+}
+
+// isDark returns true if perceived brightness of color is less than half.
+func isDark(r, g, b int) bool {
+	return 2126*r+7152*g+722*b < 10000*128
+}
+
+func getBackgroundName(dark bool) string {
+	if dark {
+		return "dark"
+	}
+
+	return "light"
+}
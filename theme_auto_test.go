@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAutoTheme(t *testing.T) {
+	test := assert.New(t)
+
+	options := map[string]string{}
+
+	tmux := &Tmux{
+		backend: func(args []string, stdin io.Reader) (string, error) {
+			return reFormatVariable.ReplaceAllStringFunc(
+				args[len(args)-1],
+				func(variable string) string {
+					return options[reFormatVariable.FindStringSubmatch(variable)[1]]
+				},
+			) + "\n", nil
+		},
+	}
+
+	config := ThemeConfig{
+		Auto: []string{
+			themeSignalOption, themeSignalWindowStyle, themeSignalColorFgBg,
+		},
+		Dark: "mocha",
+	}
+
+	testcases := []struct {
+		option      string
+		windowStyle string
+		colorfgbg   string
+		theme       string
+	}{
+		{"", "", "", "light"},
+		{"", "", "15;0", "mocha"},
+		{"", "", "0;default;15", "light"},
+		{"", "fg=red,bg=colour236", "0;15", "mocha"},
+		{"", "bg=#fdf6e3", "15;0", "light"},
+		{"", "bg=default", "15;0", "mocha"},
+		{"", "bg=brightwhite", "", "light"},
+		{"dark", "bg=white", "", "mocha"},
+		{"solarized", "bg=white", "", "solarized"},
+	}
+
+	for _, testcase := range testcases {
+		options[themeOption] = testcase.option
+		options["window-style"] = testcase.windowStyle
+		t.Setenv("COLORFGBG", testcase.colorfgbg)
+
+		theme, err := getAutoTheme(tmux, config)
+		test.NoError(err)
+		test.Equal(testcase.theme, theme, "%#v", testcase)
+	}
+
+	_, err := getAutoTheme(tmux, ThemeConfig{Auto: []string{"sky"}})
+	test.Error(err)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadThemeExtends(t *testing.T) {
+	test := assert.New(t)
+
+	dir := t.TempDir()
+
+	writeTestTheme(t, dir, "dark", "extends: dark\nsearch: \"#1e1e2e:yellow\"\n")
+	writeTestTheme(t, dir, "custom", "extends: dark\nfog:\n    text: \"240\"\n")
+
+	// dark extends embedded dark theme with the same name
+	theme, err := LoadTheme(dir, "custom")
+	test.NoError(err)
+	test.Equal("240", theme.Fog.Text)
+	test.Equal("238:236", theme.Fog.Background)
+	test.Equal("#1e1e2e:yellow", theme.Search)
+	test.Equal("16+b:green", theme.Candidate.Selected)
+
+	writeTestTheme(t, dir, "loop", "extends: loop2\n")
+	writeTestTheme(t, dir, "loop2", "extends: loop\n")
+
+	_, err = LoadTheme(dir, "loop")
+	test.Error(err)
+
+	_, err = LoadTheme(filepath.Join(dir, "missing"), "light")
+	test.NoError(err)
+}
+
+func TestCheckTheme(t *testing.T) {
+	test := assert.New(t)
+
+	dir := t.TempDir()
+
+	path := writeTestTheme(t, dir, "broken", `extends: light
+candidate:
+    normal: "#12:default"
+    selected: 300+b
+    dimmed: green
+fog: 236
+`)
+
+	sources, err := getThemeSources(dir)
+	test.NoError(err)
+
+	problems, err := checkTheme(sources, path)
+	test.NoError(err)
+	test.Equal(
+		[]themeProblem{
+			{3, "candidate.normal", `unknown color: "#12"`},
+			{4, "candidate.selected", "color index should be between 0 and 255: 300"},
+			{5, "candidate.dimmed", "unknown key"},
+			{6, "fog", "expected mapping"},
+		},
+		problems,
+	)
+
+	path = writeTestTheme(t, dir, "partial", "candidate:\n    normal: \"#fff+I\"\n")
+
+	problems, err = checkTheme(sources, path)
+	test.NoError(err)
+	test.Equal(
+		[]themeProblem{
+			{0, "identifier", "missing required key"},
+			{0, "candidate.selected", "missing required key"},
+			{0, "fog.text", "missing required key"},
+			{0, "fog.background", "missing required key"},
+		},
+		problems,
+	)
+}
+
+func TestThemeDefaults(t *testing.T) {
+	test := assert.New(t)
+
+	walkTheme(
+		&Theme{},
+		func(key string, field reflect.StructField, value reflect.Value) {
+			if style := field.Tag.Get("default"); style != "" {
+				test.NoError(validateStyle(style), "default of %s", key)
+			}
+		},
+	)
+}
+
+func writeTestTheme(t *testing.T, dir, name, contents string) string {
+	path := filepath.Join(dir, name+".theme")
+
+	err := ioutil.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestGetYAMLKeyLines(t *testing.T) {
+	test := assert.New(t)
+
+	test.Equal(
+		map[string]int{
+			"extends":            1,
+			"candidate":          3,
+			"candidate.normal":   5,
+			"candidate.type":     6,
+			"candidate.type.url": 7,
+			"fog":                8,
+			"fog.text":           9,
+		},
+		getYAMLKeyLines(
+			"extends: dark\n"+
+				"# comment: here\n"+
+				"candidate:\n"+
+				"\n"+
+				"    \"normal\": \"#fff:default\"\n"+
+				"    type:\n"+
+				"        url: 75\n"+
+				"fog:\n"+
+				"  text: 8\n",
+		),
+	)
+}
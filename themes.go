@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reconquest/karma-go"
+	yaml "gopkg.in/coryb/yaml.v2"
+)
+
+var reYAMLKey = regexp.MustCompile(`^( *)([^\s#'"-][^:#]*?|"[^"]*"|'[^']*') *:(\s|$)`)
+
+// themeProblem is a problem found in theme file, line is zero if problem is
+// not related to specific line, e.g. required key is missing.
+type themeProblem struct {
+	line    int
+	key     string
+	message string
+}
+
+// themes lists available themes or checks given theme file.
+func themes(args map[string]interface{}) error {
+	sources, err := getThemeSources(getThemePath(args))
+	if err != nil {
+		return err
+	}
+
+	if args["list"].(bool) {
+		return listThemes(sources)
+	}
+
+	path := args["<file>"].(string)
+
+	problems, err := checkTheme(sources, path)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to check theme: %s", path,
+		)
+	}
+
+	for _, problem := range problems {
+		if problem.line > 0 {
+			fmt.Printf("%s:%d: %s: %s\n", path, problem.line, problem.key, problem.message)
+		} else {
+			fmt.Printf("%s: %s: %s\n", path, problem.key, problem.message)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found in theme: %s", len(problems), path)
+	}
+
+	return nil
+}
+
+// listThemes prints themes of all sources, theme which is hidden by theme
+// with the same name from previous source is marked as overridden.
+func listThemes(sources []themeSource) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	found := map[string]bool{}
+
+	for _, source := range sources {
+		paths, err := fs.Glob(source.fs, "*.theme")
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			name := strings.TrimSuffix(path, ".theme")
+
+			note := ""
+			if found[name] {
+				note = "\t(overridden)"
+			}
+
+			found[name] = true
+
+			fmt.Fprintf(writer, "%s\t%s%s\n", name, source.dir, note)
+		}
+	}
+
+	return writer.Flush()
+}
+
+// checkTheme validates styles of theme file and reports unknown and missing
+// keys, extended theme is searched in given sources.
+func checkTheme(sources []themeSource, path string) ([]themeProblem, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.MapSlice
+
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	// decoded values have no positions, so lines are found in source
+	lines := getYAMLKeyLines(string(data))
+
+	problems := []themeProblem{}
+
+	styles := (&Theme{}).getStyles()
+
+	var walk func(prefix string, items yaml.MapSlice)
+	walk = func(prefix string, items yaml.MapSlice) {
+		for _, item := range items {
+			key := prefix + fmt.Sprint(item.Key)
+
+			_, isStyle := styles[key]
+			section, isMapping := item.Value.(yaml.MapSlice)
+
+			switch {
+			case key == "extends" && !isMapping:
+				// extended theme is checked when theme is decoded
+
+			case isStyle && isYAMLScalar(item.Value):
+				style := ""
+				if item.Value != nil {
+					style = fmt.Sprint(item.Value)
+				}
+
+				err := validateStyle(style)
+				if err != nil {
+					problems = append(problems, themeProblem{
+						line:    lines[key],
+						key:     key,
+						message: err.Error(),
+					})
+				}
+
+			case isStyle:
+				problems = append(problems, themeProblem{
+					line:    lines[key],
+					key:     key,
+					message: "expected style string",
+				})
+
+			case isThemeSection(styles, key) && isMapping:
+				walk(key+".", section)
+
+			case isThemeSection(styles, key):
+				problems = append(problems, themeProblem{
+					line:    lines[key],
+					key:     key,
+					message: "expected mapping",
+				})
+
+			default:
+				problems = append(problems, themeProblem{
+					line:    lines[key],
+					key:     key,
+					message: "unknown key",
+				})
+			}
+		}
+	}
+
+	walk("", document)
+
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	var theme Theme
+
+	name := strings.TrimSuffix(filepath.Base(path), ".theme")
+
+	// file is not one of sources, so extended theme with the same name is
+	// searched in all sources
+	err = decodeTheme(sources, -1, name, data, &theme, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	walkTheme(
+		&theme,
+		func(key string, field reflect.StructField, value reflect.Value) {
+			if value.String() == "" && field.Tag.Get("required") == "true" {
+				problems = append(problems, themeProblem{
+					key:     key,
+					message: "missing required key",
+				})
+			}
+		},
+	)
+
+	return problems, nil
+}
+
+func isThemeSection(styles map[string]string, key string) bool {
+	for style := range styles {
+		if strings.HasPrefix(style, key+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isYAMLScalar(value interface{}) bool {
+	switch value.(type) {
+	case yaml.MapSlice, []interface{}:
+		return false
+	default:
+		return true
+	}
+}
+
+// getYAMLKeyLines returns line numbers of keys of block mappings, key is a
+// path separated by dots, e.g. candidate.normal.
+func getYAMLKeyLines(data string) map[string]int {
+	type parent struct {
+		indent int
+		key    string
+	}
+
+	var (
+		lines   = map[string]int{}
+		parents = []parent{}
+	)
+
+	for number, line := range strings.Split(data, "\n") {
+		matches := reYAMLKey.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		indent := len(matches[1])
+		key := strings.Trim(matches[2], `"'`)
+
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+
+		path := key
+		if len(parents) > 0 {
+			path = parents[len(parents)-1].key + "." + key
+		}
+
+		if _, ok := lines[path]; !ok {
+			lines[path] = number + 1
+		}
+
+		parents = append(parents, parent{indent: indent, key: path})
+	}
+
+	return lines
+}
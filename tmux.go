@@ -12,6 +12,10 @@ import (
 
 type Tmux struct {
 	stdin io.Reader
+
+	// backend executes tmux commands instead of tmux binary, it's used to
+	// replay pane contents without running tmux server
+	backend func(args []string, stdin io.Reader) (string, error)
 }
 
 func (tmux *Tmux) NewWindow(args ...string) error {
@@ -23,6 +27,16 @@ func (tmux *Tmux) NewWindow(args ...string) error {
 	return nil
 }
 
+// NewWindowAt creates new window with given working directory.
+func (tmux *Tmux) NewWindowAt(path string, args ...string) error {
+	_, err := tmux.exec("new-window", "-c", path, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (tmux *Tmux) CapturePane(args ...string) (string, error) {
 	args = append([]string{"-p"}, args...)
 
@@ -34,7 +48,20 @@ func (tmux *Tmux) CapturePane(args ...string) (string, error) {
 	return pane, nil
 }
 
-func (tmux *Tmux) GetPaneSize() (int, int, error) {
+// ListPanes returns identifiers of panes.
+func (tmux *Tmux) ListPanes(args ...string) ([]string, error) {
+	panes, err := tmux.exec(
+		"list-panes",
+		append(args, "-F", "#{pane_id}")...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(panes), nil
+}
+
+func (tmux *Tmux) GetPaneSize(pane string) (int, int, error) {
 	var width int
 	var height int
 
@@ -43,6 +70,7 @@ func (tmux *Tmux) GetPaneSize() (int, int, error) {
 			"pane_width":  &width,
 			"pane_height": &height,
 		},
+		"-t", pane,
 	)
 	if err != nil {
 		return 0, 0, err
@@ -51,7 +79,9 @@ func (tmux *Tmux) GetPaneSize() (int, int, error) {
 	return width, height, nil
 }
 
-func (tmux *Tmux) Eval(values map[string]interface{}) error {
+// Eval expands given tmux format variables and scans them into values, args
+// are passed to display-message, so -t can be used to specify target pane.
+func (tmux *Tmux) Eval(values map[string]interface{}, args ...string) error {
 	format := []string{}
 	binds := []interface{}{}
 
@@ -62,14 +92,48 @@ func (tmux *Tmux) Eval(values map[string]interface{}) error {
 
 	reply, err := tmux.exec(
 		"display-message",
-		"-p",
-		strings.Join(format, "\t"),
+		append(append([]string{"-p"}, args...), strings.Join(format, "\t"))...,
 	)
 	if err != nil {
 		return err
 	}
 
-	_, err = fmt.Sscan(reply, binds...)
+	replies := strings.Split(strings.TrimSuffix(reply, "\n"), "\t")
+	if len(replies) != len(binds) {
+		return fmt.Errorf("unexpected reply from tmux: %q", reply)
+	}
+
+	for i, bind := range binds {
+		// strings can contain spaces, so they are not scanned
+		if value, ok := bind.(*string); ok {
+			*value = replies[i]
+			continue
+		}
+
+		_, err = fmt.Sscan(replies[i], bind)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ShowOption returns value of tmux option, args are passed to show-options,
+// so -s or -g can be used to specify scope. Values of array options are
+// separated by new line.
+func (tmux *Tmux) ShowOption(name string, args ...string) (string, error) {
+	value, err := tmux.exec("show-options", append(append(args, "-v"), name)...)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(value, "\n"), nil
+}
+
+// DisplayMessage shows message in the status line of tmux client.
+func (tmux *Tmux) DisplayMessage(message string) error {
+	_, err := tmux.exec("display-message", message)
 	if err != nil {
 		return err
 	}
@@ -96,13 +160,38 @@ func (tmux *Tmux) Paste(value string, args ...string) error {
 	return nil
 }
 
+func (tmux *Tmux) SetBuffer(value string, args ...string) error {
+	_, err := tmux.exec(
+		"set-buffer",
+		append(args, "--", value)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (tmux *Tmux) exec(command string, args ...string) (string, error) {
 	args = append([]string{command}, args...)
 
-	cmd := exec.Command("tmux", args...)
-	cmd.Stdin = tmux.stdin
+	var (
+		output []byte
+		err    error
+	)
+
+	if tmux.backend != nil {
+		var reply string
+
+		reply, err = tmux.backend(args, tmux.stdin)
+		output = []byte(reply)
+	} else {
+		cmd := exec.Command("tmux", args...)
+		cmd.Stdin = tmux.stdin
+
+		output, err = cmd.CombinedOutput()
+	}
 
-	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return string(output), karma.
 			Describe("args", fmt.Sprintf("%q", args)).
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"net/url"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/reconquest/executil-go"
+	"github.com/reconquest/karma-go"
+)
+
+const patternTypeURL = "url"
+
+var reURL = regexp.MustCompile(
+	`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s<>"'` + "`" + `]+` +
+		`|mailto:[^\s<>"'` + "`" + `]+` +
+		`|www\.[^\s<>"'` + "`" + `]+` +
+		// URL without scheme should have a path, otherwise it's impossible
+		// to distinguish github.com/foo from a file name like foo.go
+		`|(?:[a-zA-Z0-9-]+\.)+[a-zA-Z]{2,}(?::[0-9]+)?/[^\s<>"'` + "`" + `]*`,
+)
+
+var urlBrackets = map[byte]byte{
+	')': '(',
+	']': '[',
+	'}': '{',
+}
+
+// Opener specifies command that opens URLs with specified scheme or host,
+// opener without scheme and host matches any URL.
+type Opener struct {
+	Scheme string
+	Host   string
+
+	// Command is a template, see placeholders
+	Command string `required:"true"`
+}
+
+// normalizeURL strips trailing punctuation and unbalanced closing brackets
+// that are part of surrounding text rather than URL.
+func normalizeURL(value string) string {
+	for len(value) > 0 {
+		last := value[len(value)-1]
+
+		if strings.IndexByte(`.,;:!?'"`, last) >= 0 {
+			value = value[:len(value)-1]
+			continue
+		}
+
+		if open, ok := urlBrackets[last]; ok {
+			if strings.Count(value, string(open)) < strings.Count(value, string(last)) {
+				value = value[:len(value)-1]
+				continue
+			}
+		}
+
+		break
+	}
+
+	return value
+}
+
+// getURLTarget returns URL that should be opened for given value, https is
+// used for URLs without scheme.
+func getURLTarget(value string) string {
+	if strings.Contains(value, "://") || strings.HasPrefix(value, "mailto:") {
+		return value
+	}
+
+	return "https://" + value
+}
+
+// getHyperlinkCandidates returns candidates for OSC 8 hyperlinks which text
+// starts with identifier, all hyperlinks are returned if identifier is nil.
+// Visible text is a value of candidate and hidden URI is a target.
+func getHyperlinkCandidates(hyperlinks []Hyperlink, identifier *Identifier) []*Candidate {
+	candidates := []*Candidate{}
+
+	for _, hyperlink := range hyperlinks {
+		if identifier != nil {
+			if !strings.HasPrefix(hyperlink.Text, identifier.Value) ||
+				hyperlink.Text == identifier.Value {
+				continue
+			}
+
+			if hyperlink.X == identifier.X && hyperlink.Y == identifier.Y {
+				continue
+			}
+		}
+
+		candidates = append(candidates, &Candidate{
+			Identifier: &Identifier{
+				X: hyperlink.X,
+				Y: hyperlink.Y,
+
+				Value: hyperlink.Text,
+			},
+			Target: hyperlink.URI,
+		})
+	}
+
+	return candidates
+}
+
+// mergeHyperlinkCandidates adds hyperlink candidates to candidates found by
+// regexp, candidates with the same position and value as hyperlink are
+// replaced by hyperlink.
+func mergeHyperlinkCandidates(candidates []*Candidate, hyperlinks []*Candidate) []*Candidate {
+	if len(hyperlinks) == 0 {
+		return candidates
+	}
+
+	merged := []*Candidate{}
+
+candidates:
+	for _, candidate := range candidates {
+		for _, hyperlink := range hyperlinks {
+			if candidate.X == hyperlink.X &&
+				candidate.Y == hyperlink.Y &&
+				candidate.Value == hyperlink.Value {
+				continue candidates
+			}
+		}
+
+		merged = append(merged, candidate)
+	}
+
+	merged = append(merged, hyperlinks...)
+
+	// order of candidates at the same position is kept, parent goes first
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Y != merged[j].Y {
+			return merged[i].Y < merged[j].Y
+		}
+
+		return merged[i].X < merged[j].X
+	})
+
+	return merged
+}
+
+func getURLCandidates(lines []string, hyperlinks []Hyperlink) []*Candidate {
+	candidates := getHyperlinkCandidates(hyperlinks, nil)
+
+	for y, line := range lines {
+	matches:
+		for _, match := range reURL.FindAllStringIndex(line, -1) {
+			var (
+				value = normalizeURL(line[match[0]:match[1]])
+				x     = len([]rune(line[:match[0]]))
+			)
+
+			if value == "" {
+				continue
+			}
+
+			// text of hyperlink can look like URL too
+			for _, hyperlink := range hyperlinks {
+				if hyperlink.Y == y &&
+					x >= hyperlink.X &&
+					x < hyperlink.X+len([]rune(hyperlink.Text)) {
+					continue matches
+				}
+			}
+
+			candidates = append(candidates, &Candidate{
+				Identifier: &Identifier{
+					X: x,
+					Y: y,
+
+					Value: value,
+				},
+				Target: getURLTarget(value),
+			})
+		}
+	}
+
+	return getCandidatesInReadingOrder(candidates)
+}
+
+func getOpener(openers []Opener, target string) *Opener {
+	uri, err := url.Parse(target)
+	if err != nil {
+		uri = &url.URL{}
+	}
+
+	for i, opener := range openers {
+		if opener.Scheme != "" && !strings.EqualFold(opener.Scheme, uri.Scheme) {
+			continue
+		}
+
+		if opener.Host != "" {
+			host := strings.ToLower(uri.Hostname())
+			expected := strings.ToLower(opener.Host)
+
+			if host != expected && !strings.HasSuffix(host, "."+expected) {
+				continue
+			}
+		}
+
+		return &openers[i]
+	}
+
+	return nil
+}
+
+// openURL opens target using matching opener from config or using system
+// opener if there is no matching one.
+func openURL(openers []Opener, target string, values map[string]string) error {
+	opener := getOpener(openers, target)
+	if opener == nil {
+		debug.Printf("opening %s using system opener", target)
+
+		err := openBrowser(target)
+		if err != nil {
+			return karma.Format(
+				err,
+				"unable to open url using system opener",
+			)
+		}
+
+		return nil
+	}
+
+	debug.Printf("opening %s using %s", target, opener.Command)
+
+	values["value"] = target
+
+	args, err := expandCommand(opener.Command, values)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = executil.Run(exec.Command(args[0], args[1:]...))
+	if err != nil {
+		return karma.
+			Describe("command", args).
+			Format(
+				err,
+				"unable to open url",
+			)
+	}
+
+	return nil
+}
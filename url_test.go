@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	test := assert.New(t)
+
+	for value, expected := range map[string]string{
+		"https://example.com/foo.":                 "https://example.com/foo",
+		"https://example.com/foo),":                "https://example.com/foo",
+		"https://en.wikipedia.org/wiki/Go_(lang)":  "https://en.wikipedia.org/wiki/Go_(lang)",
+		"https://en.wikipedia.org/wiki/Go_(lang))": "https://en.wikipedia.org/wiki/Go_(lang)",
+		"github.com/foo/bar':":                     "github.com/foo/bar",
+		"https://example.com/?q=[1]]":              "https://example.com/?q=[1]",
+	} {
+		test.Equal(expected, normalizeURL(value), value)
+	}
+}
+
+func TestGetURLCandidates(t *testing.T) {
+	test := assert.New(t)
+
+	pane := &Pane{
+		Lines: []string{
+			"see (https://example.com/a.b), or github.com/foo/bar.",
+			"main.go:12 www.example.org",
+			"\x1b]8;;file:///etc/hosts\x1b\\hosts\x1b]8;;\x1b\\ " +
+				"\x1b]8;id=1;https://example.com/x\ahttps://example.com/x\x1b]8;;\a",
+		},
+	}
+
+	lines := pane.GetPrintable()
+
+	test.Equal("hosts https://example.com/x", lines[2])
+
+	values := []string{}
+	targets := []string{}
+	for _, candidate := range getURLCandidates(lines, pane.GetHyperlinks()) {
+		values = append(values, candidate.Value)
+		targets = append(targets, candidate.Target)
+	}
+
+	test.Equal(
+		[]string{
+			"https://example.com/a.b",
+			"github.com/foo/bar",
+			"www.example.org",
+			"hosts",
+			"https://example.com/x",
+		},
+		values,
+	)
+
+	test.Equal(
+		[]string{
+			"https://example.com/a.b",
+			"https://github.com/foo/bar",
+			"https://www.example.org",
+			"file:///etc/hosts",
+			"https://example.com/x",
+		},
+		targets,
+	)
+}
+
+func TestMergeHyperlinkCandidates(t *testing.T) {
+	test := assert.New(t)
+
+	pane := &Pane{
+		Lines: []string{
+			"foo.c \x1b]8;;file:///src/foo.go\x1b\\foo.go\x1b]8;;\x1b\\ " +
+				"\x1b]8;;https://example.com\afoo\x1b]8;;\a bar",
+			"$ foo.",
+		},
+	}
+
+	lines := pane.GetPrintable()
+	identifier := &Identifier{X: 2, Y: 1, Value: "foo."}
+
+	candidates, err := getCompletionCandidates(defaultRegexpCandidate, lines, identifier)
+	test.NoError(err)
+
+	candidates = mergeHyperlinkCandidates(
+		candidates,
+		getHyperlinkCandidates(pane.GetHyperlinks(), identifier),
+	)
+
+	values := []string{}
+	for _, candidate := range candidates {
+		values = append(values, candidate.Value+" "+candidate.Target)
+	}
+
+	// foo is not a candidate because it doesn't start with prefix
+	test.Equal([]string{"foo.c ", "foo.go file:///src/foo.go"}, values)
+
+	candidates[1].Selected = true
+	test.Equal("file:///src/foo.go", getCandidatesTarget(candidates))
+	test.Equal("foo.go", getCandidatesText(candidates))
+}
+
+func TestOpenURL(t *testing.T) {
+	test := assert.New(t)
+
+	dir := t.TempDir()
+
+	stub := filepath.Join(dir, "opener")
+	err := os.WriteFile(
+		stub,
+		[]byte("#!/bin/sh\necho \"$@\" > \"$(dirname \"$0\")/opened\"\n"),
+		0755,
+	)
+	test.NoError(err)
+
+	openers := []Opener{
+		{Scheme: "mailto", Command: stub + " mail {value}"},
+		{Host: "github.com", Command: stub + " github {value}"},
+		{Command: stub + " default {value}"},
+	}
+
+	for target, expected := range map[string]string{
+		"mailto:we@reconquest.io":   "mail mailto:we@reconquest.io",
+		"https://github.com/foo":    "github https://github.com/foo",
+		"https://gist.github.com/x": "github https://gist.github.com/x",
+		"https://notgithub.com/foo": "default https://notgithub.com/foo",
+	} {
+		err := openURL(openers, target, map[string]string{})
+		test.NoError(err)
+
+		opened, err := os.ReadFile(filepath.Join(dir, "opened"))
+		test.NoError(err)
+		test.Equal(expected+"\n", string(opened), target)
+	}
+}
+
+func TestOpenURLSystemOpenerError(t *testing.T) {
+	test := assert.New(t)
+
+	// system opener can't be found
+	t.Setenv("PATH", t.TempDir())
+
+	err := openURL(nil, "https://example.com", map[string]string{})
+	test.Error(err)
+	test.Contains(err.Error(), "unable to open url using system opener")
+}